@@ -0,0 +1,213 @@
+package nanoproto
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+type AccountBalance struct {
+	Balance    Raw `json:"balance"`
+	Receivable Raw `json:"receivable"`
+}
+
+// BlockInfo is what the node knows about a block.
+type BlockInfo struct {
+	BlockAccount   string          `json:"block_account"`
+	Amount         Raw             `json:"amount"`
+	Balance        Raw             `json:"balance"`
+	Height         uint64          `json:"height,string"`
+	LocalTimestamp uint64          `json:"local_timestamp,string"`
+	Successor      string          `json:"successor"`
+	Confirmed      bool            `json:"confirmed,string"`
+	Subtype        string          `json:"subtype"`
+	Contents       json.RawMessage `json:"contents"` // The block itself, see Block
+}
+
+// Block decodes Contents as a state block. Legacy blocks use other fields and fail to decode.
+func (b BlockInfo) Block() (StateBlock, error) {
+	var block StateBlock
+	err := json.Unmarshal(b.Contents, &block)
+	return block, err
+}
+
+type BlockCount struct {
+	Count     uint64 `json:"count,string"`
+	Unchecked uint64 `json:"unchecked,string"`
+	Cemented  uint64 `json:"cemented,string"`
+}
+
+type Version struct {
+	RPCVersion        string `json:"rpc_version"`
+	StoreVersion      string `json:"store_version"`
+	ProtocolVersion   string `json:"protocol_version"`
+	NodeVendor        string `json:"node_vendor"`
+	StoreVendor       string `json:"store_vendor"`
+	Network           string `json:"network"`
+	NetworkIdentifier string `json:"network_identifier"`
+	BuildInfo         string `json:"build_info"`
+}
+
+// Telemetry is the network telemetry averaged by the node over its peers.
+type Telemetry struct {
+	BlockCount        uint64 `json:"block_count,string"`
+	CementedCount     uint64 `json:"cemented_count,string"`
+	UncheckedCount    uint64 `json:"unchecked_count,string"`
+	AccountCount      uint64 `json:"account_count,string"`
+	BandwidthCap      uint64 `json:"bandwidth_cap,string"`
+	PeerCount         uint64 `json:"peer_count,string"`
+	ProtocolVersion   string `json:"protocol_version"`
+	Uptime            uint64 `json:"uptime,string"`
+	GenesisBlock      string `json:"genesis_block"`
+	MajorVersion      string `json:"major_version"`
+	MinorVersion      string `json:"minor_version"`
+	PatchVersion      string `json:"patch_version"`
+	PreReleaseVersion string `json:"pre_release_version"`
+	Maker             string `json:"maker"`
+	Timestamp         uint64 `json:"timestamp,string"`
+	ActiveDifficulty  string `json:"active_difficulty"`
+}
+
+// ActiveDifficulty holds the work thresholds in hex, as the node reports them.
+type ActiveDifficulty struct {
+	NetworkMinimum        string  `json:"network_minimum"`
+	NetworkReceiveMinimum string  `json:"network_receive_minimum"`
+	NetworkCurrent        string  `json:"network_current"`
+	NetworkReceiveCurrent string  `json:"network_receive_current"`
+	Multiplier            float64 `json:"multiplier,string"`
+}
+
+type WorkValidation struct {
+	ValidAll     bool // Valid for any block (send/change threshold)
+	ValidReceive bool // Valid for receive and open blocks
+	Difficulty   string
+	Multiplier   float64
+}
+
+// nodeMap decodes the maps nodes return, which come as an empty string when there are no entries.
+type nodeMap[V any] map[string]V
+
+func (m *nodeMap[V]) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte(`""`)) {
+		*m = nodeMap[V]{}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*map[string]V)(m))
+}
+
+func (r *RPC) AccountBalance(account string) (AccountBalance, error) {
+	return request[AccountBalance](r, map[string]interface{}{
+		"action":  "account_balance",
+		"account": account,
+	})
+}
+
+func (r *RPC) AccountRepresentative(account string) (string, error) {
+	x, err := request[struct {
+		Representative string `json:"representative"`
+	}](r, map[string]interface{}{
+		"action":  "account_representative",
+		"account": account,
+	})
+
+	return x.Representative, err
+}
+
+func (r *RPC) AccountBlockCount(account string) (uint64, error) {
+	x, err := request[struct {
+		BlockCount uint64 `json:"block_count,string"`
+	}](r, map[string]interface{}{
+		"action":  "account_block_count",
+		"account": account,
+	})
+
+	return x.BlockCount, err
+}
+
+func (r *RPC) BlockInfo(hash string) (BlockInfo, error) {
+	return request[BlockInfo](r, map[string]interface{}{
+		"action":     "block_info",
+		"json_block": "true",
+		"hash":       hash,
+	})
+}
+
+// BlocksInfo returns the info of several blocks keyed by hash. The node fails the call when one is missing.
+func (r *RPC) BlocksInfo(hashes []string) (map[string]BlockInfo, error) {
+	x, err := request[struct {
+		Blocks nodeMap[BlockInfo] `json:"blocks"`
+	}](r, map[string]interface{}{
+		"action":     "blocks_info",
+		"json_block": "true",
+		"hashes":     hashes,
+	})
+
+	return x.Blocks, err
+}
+
+func (r *RPC) BlockCount() (BlockCount, error) {
+	return request[BlockCount](r, map[string]interface{}{
+		"action": "block_count",
+	})
+}
+
+// Receivable returns up to count receivable blocks of account with their amounts, keyed by block hash.
+func (r *RPC) Receivable(account string, count int) (map[string]Raw, error) {
+	x, err := request[struct {
+		Blocks nodeMap[Raw] `json:"blocks"`
+	}](r, map[string]interface{}{
+		"action":    "receivable",
+		"account":   account,
+		"count":     count,
+		"threshold": "1", // Makes the node return the amounts
+	})
+
+	return x.Blocks, err
+}
+
+// Frontiers returns up to count account frontiers starting at account, keyed by account.
+func (r *RPC) Frontiers(account string, count int) (map[string]string, error) {
+	x, err := request[struct {
+		Frontiers nodeMap[string] `json:"frontiers"`
+	}](r, map[string]interface{}{
+		"action":  "frontiers",
+		"account": account,
+		"count":   count,
+	})
+
+	return x.Frontiers, err
+}
+
+func (r *RPC) Version() (Version, error) {
+	return request[Version](r, map[string]interface{}{
+		"action": "version",
+	})
+}
+
+func (r *RPC) Telemetry() (Telemetry, error) {
+	return request[Telemetry](r, map[string]interface{}{
+		"action": "telemetry",
+	})
+}
+
+func (r *RPC) ActiveDifficulty() (ActiveDifficulty, error) {
+	return request[ActiveDifficulty](r, map[string]interface{}{
+		"action": "active_difficulty",
+	})
+}
+
+// WorkValidate checks work against the block root hash.
+func (r *RPC) WorkValidate(work, hash string) (WorkValidation, error) {
+	x, err := request[struct {
+		ValidAll     string  `json:"valid_all"`
+		ValidReceive string  `json:"valid_receive"`
+		Difficulty   string  `json:"difficulty"`
+		Multiplier   float64 `json:"multiplier,string"`
+	}](r, map[string]interface{}{
+		"action": "work_validate",
+		"work":   work,
+		"hash":   hash,
+	})
+
+	return WorkValidation{x.ValidAll == "1", x.ValidReceive == "1", x.Difficulty, x.Multiplier}, err
+}
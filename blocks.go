@@ -0,0 +1,129 @@
+package nanoproto
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ZeroHash is used as the link of change blocks.
+const ZeroHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// StateBlock is a state block in the node's JSON representation.
+type StateBlock struct {
+	Type           string `json:"type"`
+	Account        string `json:"account"`
+	Previous       string `json:"previous"`
+	Representative string `json:"representative"`
+	Balance        Raw    `json:"balance"`
+	Link           string `json:"link"`
+	Signature      string `json:"signature,omitempty"`
+	Work           string `json:"work,omitempty"`
+}
+
+func NewChangeBlock(account, previous, representative string, balance Raw) *StateBlock {
+	return &StateBlock{
+		Type:           "state",
+		Account:        account,
+		Previous:       previous,
+		Representative: representative,
+		Balance:        balance,
+		Link:           ZeroHash,
+	}
+}
+
+// NewSendBlock builds a send block leaving the account with balance, link being the destination public key.
+func NewSendBlock(account, previous, representative string, balance Raw, link string) *StateBlock {
+	block := NewChangeBlock(account, previous, representative, balance)
+	block.Link = link
+	return block
+}
+
+// Hash returns the uppercase hex block hash. Signature and work aren't part of it.
+func (b *StateBlock) Hash() (string, error) {
+	hash, err := stateBlockHash(b.Account, b.Previous, b.Representative, b.Balance, b.Link)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToUpper(hex.EncodeToString(hash)), nil
+}
+
+// Sign signs the block with the account's private key.
+func (b *StateBlock) Sign(privateKey string) error {
+	address, err := PrivateKeyToAddress(privateKey)
+	if err != nil {
+		return err
+	}
+	if address != strings.Replace(b.Account, "xrb_", "nano_", 1) {
+		return fmt.Errorf("private key belongs to %s, not %s", address, b.Account)
+	}
+
+	hash, err := stateBlockHash(b.Account, b.Previous, b.Representative, b.Balance, b.Link)
+	if err != nil {
+		return err
+	}
+
+	privKeyBytes, _ := hex.DecodeString(privateKey)
+	signature, err := NewEd25519().Sign(hash, privKeyBytes)
+	if err != nil {
+		return fmt.Errorf("failed to sign block: %v", err)
+	}
+
+	b.Signature = strings.ToUpper(hex.EncodeToString(signature))
+	return nil
+}
+
+// VerifySignature checks that the block is signed by its account.
+func (b *StateBlock) VerifySignature() error {
+	hash, err := stateBlockHash(b.Account, b.Previous, b.Representative, b.Balance, b.Link)
+	if err != nil {
+		return err
+	}
+
+	pubKey, _ := nanoAddressToPublicKey(b.Account)
+	pubKeyBytes, _ := hex.DecodeString(pubKey)
+
+	signature, err := hex.DecodeString(b.Signature)
+	if err != nil || !NewEd25519().Verify(hash, pubKeyBytes, signature) {
+		return errors.New("invalid block signature")
+	}
+
+	return nil
+}
+
+// Map returns the block in the form expected by ProcessChangeRepBlock.
+func (b *StateBlock) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"type":           b.Type,
+		"account":        b.Account,
+		"previous":       b.Previous,
+		"representative": b.Representative,
+		"balance":        b.Balance.String(),
+		"link":           b.Link,
+		"signature":      b.Signature,
+		"work":           b.Work,
+	}
+}
+
+// PlanMessage builds the unsigned, work-less change blocks storing data on account, chained from frontier.
+// Change blocks don't move funds, so every block keeps the given balance.
+func PlanMessage(account, frontier string, balance Raw, data []byte) ([]StateBlock, error) {
+	var blocks []StateBlock
+	previous := frontier
+
+	for _, address := range CreateMessage(data) {
+		block := NewChangeBlock(account, previous, address, balance)
+
+		hash, err := block.Hash()
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, *block)
+		previous = hash
+	}
+
+	return blocks, nil
+}
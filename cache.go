@@ -0,0 +1,322 @@
+package nanoproto
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// HistoryCache keeps the raw history of accounts between reads so only newer blocks are fetched from the node.
+type HistoryCache interface {
+	// Load returns the cached blocks of account, oldest first.
+	Load(account string) ([]AccountHistoryRepChange, error)
+	// Append adds blocks following the cached ones, oldest first. Blocks already stored must be skipped,
+	// two reads of the same account may append the same blocks.
+	Append(account string, blocks []AccountHistoryRepChange) error
+	// Reset forgets the account, used when the cached chain no longer matches the node (e.g. after a rollback).
+	Reset(account string) error
+}
+
+// SetHistoryCache makes reads go through the cache, nil disables caching.
+func (s *NanoDataStorage) SetHistoryCache(cache HistoryCache) {
+	s.cache = cache
+}
+
+// history returns every block of the account, oldest first, verified and cut at the quorum-confirmed block
+// when those modes are on.
+func (s *NanoDataStorage) history(address string) ([]AccountHistoryRepChange, error) {
+	// Limits are read before the history, blocks published in between are dropped from the result
+	frontier := ""
+	if s.verify {
+		info, err := s.rpc.AccountInfo(address)
+		if err != nil {
+			return nil, err
+		}
+
+		frontier = info.Frontier
+	}
+
+	confirmed := ""
+	if s.quorum != nil {
+		var err error
+		if confirmed, err = s.quorum.confirmed(address); err != nil {
+			return nil, err
+		}
+	}
+
+	history, err := s.cachedHistory(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.verify {
+		if history, err = verifiedHistory(address, history, frontier); err != nil {
+			return nil, err
+		}
+	}
+
+	if confirmed != "" {
+		var ok bool
+		if history, ok = truncateHistory(history, confirmed); !ok {
+			return nil, fmt.Errorf("block %s confirmed by the quorum is missing from the history", confirmed)
+		}
+	}
+
+	return history, nil
+}
+
+// cachedHistory returns every block of the account, oldest first, fetching only what the cache doesn't have.
+func (s *NanoDataStorage) cachedHistory(address string) ([]AccountHistoryRepChange, error) {
+	if s.cache == nil {
+		return s.rpc.RawHistory(address, "")
+	}
+
+	cached, err := s.cache.Load(address)
+	if err != nil {
+		return nil, err
+	}
+
+	since := ""
+	if len(cached) > 0 {
+		since = cached[len(cached)-1].Hash
+	}
+
+	fresh, err := s.rpc.RawHistory(address, since)
+
+	if since != "" && isNodeError(err, "Block not found") {
+		// The node doesn't know our last cached block anymore (e.g. it was rolled back), start over
+		if err := s.cache.Reset(address); err != nil {
+			return nil, err
+		}
+
+		cached = nil
+		fresh, err = s.rpc.RawHistory(address, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fresh) > 0 {
+		if err := s.cache.Append(address, fresh); err != nil {
+			return nil, err
+		}
+	}
+
+	return append(cached, fresh...), nil
+}
+
+// DiskHistoryCache stores one append-only file of JSON lines per account in a directory.
+type DiskHistoryCache struct {
+	dir    string
+	mu     sync.Mutex
+	hashes map[string]map[string]bool // Blocks stored per account, filled on first use
+}
+
+func NewDiskHistoryCache(dir string) (*DiskHistoryCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &DiskHistoryCache{dir: dir, hashes: map[string]map[string]bool{}}, nil
+}
+
+func (c *DiskHistoryCache) path(account string) string {
+	return filepath.Join(c.dir, strings.Replace(account, "xrb_", "nano_", 1)+".jsonl")
+}
+
+func (c *DiskHistoryCache) Load(account string) ([]AccountHistoryRepChange, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.load(account)
+}
+
+// load reads the file of account. A torn or corrupt line (e.g. from a crash during Append) is cut off
+// together with everything after it, so the next Append continues from the last good block.
+func (c *DiskHistoryCache) load(account string) ([]AccountHistoryRepChange, error) {
+	file, err := os.Open(c.path(account))
+	if errors.Is(err, os.ErrNotExist) {
+		c.hashes[account] = map[string]bool{}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var blocks []AccountHistoryRepChange
+	seen := map[string]bool{}
+	reader := bufio.NewReader(file)
+	offset := int64(0)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		var block AccountHistoryRepChange
+		if err == io.EOF || json.Unmarshal(line, &block) != nil {
+			// Lines are always written with their newline, anything else is a torn write
+			if err := os.Truncate(c.path(account), offset); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		offset += int64(len(line))
+
+		if seen[block.Hash] {
+			continue
+		}
+
+		seen[block.Hash] = true
+		blocks = append(blocks, block)
+	}
+
+	c.hashes[account] = seen
+	return blocks, nil
+}
+
+// Append skips blocks already stored, so concurrent readers of the same account can't duplicate them.
+func (c *DiskHistoryCache) Append(account string, blocks []AccountHistoryRepChange) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.hashes[account]; !ok {
+		if _, err := c.load(account); err != nil {
+			return err
+		}
+	}
+
+	seen := c.hashes[account]
+
+	file, err := os.OpenFile(c.path(account), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+
+	for _, block := range blocks {
+		if seen[block.Hash] {
+			continue
+		}
+
+		if err := encoder.Encode(block); err != nil {
+			file.Close()
+			return err
+		}
+
+		seen[block.Hash] = true
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		delete(c.hashes, account) // Unsure what made it to disk, reload on next use
+		return err
+	}
+
+	return file.Close()
+}
+
+func (c *DiskHistoryCache) Reset(account string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.hashes, account)
+
+	err := os.Remove(c.path(account))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// MemoryHistoryCache keeps the history of the most recently used accounts in memory.
+type MemoryHistoryCache struct {
+	capacity int
+	mu       sync.Mutex
+	order    *list.List // Front is the most recently used
+	accounts map[string]*list.Element
+}
+
+type memoryCacheEntry struct {
+	account string
+	blocks  []AccountHistoryRepChange
+	hashes  map[string]bool
+}
+
+// NewMemoryHistoryCache creates a cache holding at most capacity accounts.
+func NewMemoryHistoryCache(capacity int) *MemoryHistoryCache {
+	return &MemoryHistoryCache{capacity: capacity, order: list.New(), accounts: map[string]*list.Element{}}
+}
+
+func (c *MemoryHistoryCache) Load(account string) ([]AccountHistoryRepChange, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.accounts[account]
+	if !ok {
+		return nil, nil
+	}
+
+	c.order.MoveToFront(element)
+	blocks := element.Value.(*memoryCacheEntry).blocks
+
+	return blocks[:len(blocks):len(blocks)], nil
+}
+
+// Append skips blocks already stored, so concurrent readers of the same account can't duplicate them.
+func (c *MemoryHistoryCache) Append(account string, blocks []AccountHistoryRepChange) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.accounts[account]
+	if ok {
+		c.order.MoveToFront(element)
+	} else {
+		element = c.order.PushFront(&memoryCacheEntry{account, nil, map[string]bool{}})
+		c.accounts[account] = element
+	}
+
+	entry := element.Value.(*memoryCacheEntry)
+	for _, block := range blocks {
+		if !entry.hashes[block.Hash] {
+			entry.hashes[block.Hash] = true
+			entry.blocks = append(entry.blocks, block)
+		}
+	}
+
+	for c.capacity > 0 && c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.accounts, oldest.Value.(*memoryCacheEntry).account)
+	}
+
+	return nil
+}
+
+func (c *MemoryHistoryCache) Reset(account string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.accounts[account]; ok {
+		c.order.Remove(element)
+		delete(c.accounts, account)
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package nanoproto
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+const cacheAccount = "nano_3i1aq1cchnmbn9x5rsbap8b15akfh7wj7pwskuzi7ahz8oq6cobd99d4r3b7"
+
+func cacheBlocks(from, to int) []AccountHistoryRepChange {
+	var blocks []AccountHistoryRepChange
+	for i := from; i < to; i++ {
+		blocks = append(blocks, AccountHistoryRepChange{Type: "state", Subtype: "change", Hash: fmt.Sprintf("%064X", i), Height: fmt.Sprint(i + 1)})
+	}
+	return blocks
+}
+
+func blockHashes(blocks []AccountHistoryRepChange) []string {
+	var hashes []string
+	for _, block := range blocks {
+		hashes = append(hashes, block.Hash)
+	}
+	return hashes
+}
+
+func TestDiskHistoryCacheTornWrite(t *testing.T) {
+	cache, err := NewDiskHistoryCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.Append(cacheAccount, cacheBlocks(0, 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash in the middle of writing the next line
+	file, err := os.OpenFile(cache.path(cacheAccount), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"type":"state","hash":"00`)
+	file.Close()
+
+	// A fresh cache, as after a restart
+	cache, _ = NewDiskHistoryCache(cache.dir)
+
+	blocks, err := cache.Load(cacheAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 3 {
+		t.Fatalf("loaded %d blocks, want 3", len(blocks))
+	}
+
+	if err := cache.Append(cacheAccount, cacheBlocks(3, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, err = cache.Load(cacheAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(blockHashes(blocks)) != fmt.Sprint(blockHashes(cacheBlocks(0, 5))) {
+		t.Fatalf("loaded %v after the torn write, want blocks 0 to 4", blockHashes(blocks))
+	}
+}
+
+func TestDiskHistoryCacheCorruptLine(t *testing.T) {
+	cache, _ := NewDiskHistoryCache(t.TempDir())
+	cache.Append(cacheAccount, cacheBlocks(0, 2))
+
+	file, _ := os.OpenFile(cache.path(cacheAccount), os.O_APPEND|os.O_WRONLY, 0)
+	file.WriteString("not json\n")
+	file.Close()
+
+	cache, _ = NewDiskHistoryCache(cache.dir)
+	cache.Append(cacheAccount, cacheBlocks(2, 3))
+
+	blocks, _ := cache.Load(cacheAccount)
+	if len(blocks) != 3 {
+		t.Fatalf("loaded %d blocks, want 3", len(blocks))
+	}
+}
+
+func TestHistoryCacheConcurrentAppend(t *testing.T) {
+	disk, _ := NewDiskHistoryCache(t.TempDir())
+	caches := map[string]HistoryCache{"memory": NewMemoryHistoryCache(4), "disk": disk}
+
+	for name, cache := range caches {
+		cache.Append(cacheAccount, cacheBlocks(0, 2))
+
+		// Two readers fetched the same new blocks
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cache.Append(cacheAccount, cacheBlocks(1, 4))
+			}()
+		}
+		wg.Wait()
+
+		blocks, err := cache.Load(cacheAccount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(blockHashes(blocks)) != fmt.Sprint(blockHashes(cacheBlocks(0, 4))) {
+			t.Errorf("%s: cached %d blocks, want 4 without duplicates", name, len(blocks))
+		}
+	}
+}
+
+// Only a node that no longer knows the cached head makes the cache start over, other errors are returned.
+func TestCachedHistoryReset(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+
+	storage := NewNanoDataStorage(rpc, &address, &privateKey)
+	cache := NewMemoryHistoryCache(4)
+	storage.SetHistoryCache(cache)
+
+	if err := storage.PutData([]byte("cached")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.GetData(&address); err != nil {
+		t.Fatal(err)
+	}
+
+	cached, _ := cache.Load(address)
+
+	busy := true
+	node.hook = func(action string, request map[string]interface{}) (interface{}, bool) {
+		if action == "account_history" && busy {
+			return map[string]string{"error": "Too many requests"}, true
+		}
+		return nil, false
+	}
+
+	if _, err := storage.GetData(&address); !isNodeError(err, "Too many requests") {
+		t.Fatalf("GetData = %v, want the node error", err)
+	}
+	if kept, _ := cache.Load(address); len(kept) != len(cached) || node.calls["account_history"] != 2 {
+		t.Fatalf("cache holds %d blocks after %d history calls, want %d after one", len(kept), node.calls["account_history"], len(cached))
+	}
+
+	// The cached frontier is rolled back and replaced by another message
+	busy = false
+	node.mu.Lock()
+	node.chains[address] = node.chains[address][:1]
+	node.mu.Unlock()
+
+	if err := storage.PutData([]byte("rewritten")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := storage.GetData(&address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || string(data[0]) != "rewritten" {
+		t.Fatalf("read %q after the rollback", data)
+	}
+}
@@ -0,0 +1,127 @@
+package nanoproto
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Carrier decides which blocks of an account hold data and how a 32-byte chunk is encoded in them.
+type Carrier interface {
+	// Subtype is the state block subtype of the blocks built by Block, passed to the node on publishing.
+	Subtype() string
+	// Block builds the unsigned block carrying chunk on top of previous, given the account's current
+	// representative and balance.
+	Block(account, previous, representative string, balance Raw, chunk []byte) (*StateBlock, error)
+	// Chunk returns the chunk carried by a raw history block, false for blocks that don't carry data.
+	Chunk(block AccountHistoryRepChange) ([]byte, bool)
+}
+
+// RepresentativeCarrier stores chunks as the representative of change blocks. It is the default carrier.
+type RepresentativeCarrier struct{}
+
+func (RepresentativeCarrier) Subtype() string {
+	return "change"
+}
+
+func (RepresentativeCarrier) Block(account, previous, representative string, balance Raw, chunk []byte) (*StateBlock, error) {
+	address, err := publicKeyToNanoAddress(chunk)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewChangeBlock(account, previous, address, balance), nil
+}
+
+func (RepresentativeCarrier) Chunk(block AccountHistoryRepChange) ([]byte, bool) {
+	if !isChangeBlock(block) {
+		return nil, false
+	}
+
+	pubKey, err := nanoAddressToPublicKey(block.Representative)
+	if err != nil {
+		return nil, false
+	}
+
+	chunk, _ := hex.DecodeString(pubKey)
+	return chunk, true
+}
+
+// LinkCarrier stores chunks as the link (destination) of send blocks, each sending Amount raw.
+// The representative is left alone, but the account must hold Amount raw for every block written.
+// Every send is read as a chunk, since the destination is the data. Payments made from the account
+// between messages fall outside any frame and are skipped; a payment made while a message is being
+// written corrupts it, so don't share the account with a wallet that sends on its own.
+type LinkCarrier struct {
+	Amount Raw // Sent per block, at least 1 raw
+}
+
+func (LinkCarrier) Subtype() string {
+	return "send"
+}
+
+func (c LinkCarrier) Block(account, previous, representative string, balance Raw, chunk []byte) (*StateBlock, error) {
+	if c.Amount.IsZero() {
+		return nil, errors.New("send amount must be at least 1 raw")
+	}
+
+	remaining, err := balance.Sub(c.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("can't send %s raw: %v", c.Amount, err)
+	}
+
+	return NewSendBlock(account, previous, representative, remaining, strings.ToUpper(hex.EncodeToString(chunk))), nil
+}
+
+func (LinkCarrier) Chunk(block AccountHistoryRepChange) ([]byte, bool) {
+	if block.Type != "state" || block.Subtype != "send" {
+		return nil, false
+	}
+
+	chunk, err := hex.DecodeString(block.Link)
+	if err != nil || len(chunk) != 32 {
+		return nil, false
+	}
+
+	return chunk, true
+}
+
+// SetCarrier selects the carrier used to write and read account, RepresentativeCarrier when not set.
+// It is safe to call while other goroutines read or write.
+func (s *NanoDataStorage) SetCarrier(account string, carrier Carrier) {
+	s.carriersMu.Lock()
+	defer s.carriersMu.Unlock()
+
+	if s.carriers == nil {
+		s.carriers = map[string]Carrier{}
+	}
+
+	s.carriers[strings.Replace(account, "xrb_", "nano_", 1)] = carrier
+}
+
+func (s *NanoDataStorage) carrier(account string) Carrier {
+	s.carriersMu.RLock()
+	defer s.carriersMu.RUnlock()
+
+	if carrier, ok := s.carriers[strings.Replace(account, "xrb_", "nano_", 1)]; ok {
+		return carrier
+	}
+
+	return RepresentativeCarrier{}
+}
+
+// carried returns the blocks of history that carry data, with the chunks they hold.
+func carried(carrier Carrier, history []AccountHistoryRepChange) ([]AccountHistoryRepChange, []byte) {
+	var blocks []AccountHistoryRepChange
+	stream := make([]byte, 0, len(history)*32)
+
+	for _, item := range history {
+		if chunk, ok := carrier.Chunk(item); ok {
+			blocks = append(blocks, item)
+			stream = append(stream, chunk...)
+		}
+	}
+
+	return blocks, stream
+}
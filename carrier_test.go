@@ -0,0 +1,71 @@
+package nanoproto
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Payments from the account between messages are sends too, the reader must skip them.
+func TestLinkCarrierSkipsPayments(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+
+	storage := NewNanoDataStorage(rpc, &address, &privateKey)
+	storage.SetCarrier(address, LinkCarrier{Amount: NewRaw(1)})
+
+	if err := storage.PutData([]byte("before the payment")); err != nil {
+		t.Fatal(err)
+	}
+
+	node.mu.Lock()
+	chain := node.chains[address]
+	last := chain[len(chain)-1]
+	paid, _ := last.Balance.Sub(NewRaw(1000))
+	node.chains[address] = append(chain, AccountHistoryRepChange{Type: "state", Subtype: "send", Hash: strings.Repeat("5A", 32), Previous: last.Hash, Representative: last.Representative, Balance: paid, Link: strings.Repeat("C3", 32)})
+	node.mu.Unlock()
+
+	if err := storage.PutData(testPayload); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := storage.GetData(&address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 || string(data[0]) != "before the payment" || string(data[1]) != string(testPayload) {
+		t.Fatalf("read %q", data)
+	}
+
+	// The representative carrier ignores sends altogether
+	storage.SetCarrier(address, RepresentativeCarrier{})
+	if data, err := storage.GetData(&address); err != nil || len(data) != 0 {
+		t.Fatalf("representative carrier read %d messages, %v", len(data), err)
+	}
+}
+
+func TestSetCarrierConcurrent(t *testing.T) {
+	storage := NewNanoDataStorage(nil, nil, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			account := fmt.Sprintf("nano_%d", i%2)
+			for j := 0; j < 100; j++ {
+				storage.SetCarrier(account, LinkCarrier{Amount: NewRaw(uint64(j + 1))})
+				storage.carrier(account)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if _, ok := storage.carrier("xrb_1").(LinkCarrier); !ok {
+		t.Fatal("carrier not set for the xrb_ spelling of the account")
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/2xxn/go-nanoproto"
+)
+
+func runPut(args []string) error {
+	fs := flag.NewFlagSet("put", flag.ExitOnError)
+	node := nodeFlag(fs)
+	keys := addKeyFlags(fs)
+	file := fs.String("file", "-", "file to store, - for stdin")
+	representative := fs.String("representative", "", "change back to this representative after the upload")
+	carrier := carrierFlag(fs)
+	fs.Parse(args)
+
+	rpc, err := newRPC(*node)
+	if err != nil {
+		return err
+	}
+
+	privateKey, address, err := keys.account()
+	if err != nil {
+		return err
+	}
+
+	data, err := readInput(*file)
+	if err != nil {
+		return err
+	}
+
+	c, err := carrier()
+	if err != nil {
+		return err
+	}
+
+	storage := nanoproto.NewNanoDataStorage(rpc, &address, &privateKey)
+	storage.SetCarrier(address, c)
+	if err := storage.SetRepresentative(*representative); err != nil {
+		return err
+	}
+
+	if err := storage.PutData(data); err != nil {
+		return err
+	}
+
+	fmt.Printf("stored %d bytes on %s\n", len(data), address)
+	return nil
+}
+
+// addressArg returns the account passed as the first positional argument, falling back to NANOPROTO_ADDRESS.
+func addressArg(fs *flag.FlagSet) (string, error) {
+	address := env("NANOPROTO_ADDRESS", "")
+	if fs.NArg() > 0 {
+		address = fs.Arg(0)
+	}
+
+	if address == "" {
+		return "", fmt.Errorf("usage: nanoproto %s [flags] <address>", fs.Name())
+	}
+	return address, nil
+}
+
+type jsonMessage struct {
+	Ref         string    `json:"ref"`
+	FirstHash   string    `json:"first_hash"`
+	LastHash    string    `json:"last_hash"`
+	FirstHeight uint64    `json:"first_height"`
+	LastHeight  uint64    `json:"last_height"`
+	Timestamp   time.Time `json:"timestamp"`
+	Data        []byte    `json:"data"`
+}
+
+func runGet(args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	node := nodeFlag(fs)
+	format := fs.String("format", "hex", "output format: raw, hex, base64 or json")
+	carrier := carrierFlag(fs)
+	fs.Parse(args)
+
+	rpc, err := newRPC(*node)
+	if err != nil {
+		return err
+	}
+
+	address, err := addressArg(fs)
+	if err != nil {
+		return err
+	}
+
+	c, err := carrier()
+	if err != nil {
+		return err
+	}
+
+	storage := nanoproto.NewNanoDataStorage(rpc, nil, nil)
+	storage.SetCarrier(address, c)
+
+	messages, err := storage.GetDataRefs(&address)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "raw":
+		for _, message := range messages {
+			os.Stdout.Write(message.Data)
+		}
+	case "hex":
+		for _, message := range messages {
+			fmt.Println(hex.EncodeToString(message.Data))
+		}
+	case "base64":
+		for _, message := range messages {
+			fmt.Println(base64.StdEncoding.EncodeToString(message.Data))
+		}
+	case "json":
+		out := make([]jsonMessage, 0, len(messages))
+		for _, m := range messages {
+			out = append(out, jsonMessage{m.Ref.String(), m.Ref.FirstHash, m.Ref.LastHash, m.Ref.FirstHeight, m.Ref.LastHeight, m.Ref.Timestamp, m.Data})
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(out)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	return nil
+}
+
+func runInspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	node := nodeFlag(fs)
+	carrier := carrierFlag(fs)
+	fs.Parse(args)
+
+	rpc, err := newRPC(*node)
+	if err != nil {
+		return err
+	}
+
+	address, err := addressArg(fs)
+	if err != nil {
+		return err
+	}
+
+	history, err := rpc.RawHistory(address, "")
+	if err != nil {
+		return err
+	}
+
+	c, err := carrier()
+	if err != nil {
+		return err
+	}
+
+	storage := nanoproto.NewNanoDataStorage(rpc, nil, nil)
+	storage.SetCarrier(address, c)
+
+	messages, err := storage.GetDataRefs(&address)
+	if err != nil {
+		return err
+	}
+
+	// Annotate the blocks where frames begin and end
+	marks := map[string][]string{}
+	for i, message := range messages {
+		marks[message.Ref.FirstHash] = append(marks[message.Ref.FirstHash], fmt.Sprintf("begin #%d (%d bytes)", i, len(message.Data)))
+		marks[message.Ref.LastHash] = append(marks[message.Ref.LastHash], fmt.Sprintf("end #%d", i))
+	}
+
+	fmt.Printf("%-7s %-64s %-8s %-64s %s\n", "HEIGHT", "HASH", "SUBTYPE", "CHUNK", "FRAME")
+
+	for _, block := range history {
+		subtype := block.Subtype
+		if subtype == "" {
+			subtype = block.Type
+		}
+
+		chunk := ""
+		if data, ok := c.Chunk(block); ok {
+			chunk = strings.ToUpper(hex.EncodeToString(data))
+		}
+
+		fmt.Printf("%-7s %-64s %-8s %-64s %s\n", block.Height, block.Hash, subtype, chunk, strings.Join(marks[block.Hash], ", "))
+	}
+
+	fmt.Printf("\n%d blocks, %d messages\n", len(history), len(messages))
+	return nil
+}
+
+func runKeygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	seed := fs.String("seed", env("NANOPROTO_SEED", ""), "hex wallet seed, a random one is generated when empty (NANOPROTO_SEED)")
+	index := fs.Uint("index", 0, "first account index")
+	count := fs.Uint("count", 1, "number of accounts to derive")
+	fs.Parse(args)
+
+	if *seed == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return err
+		}
+
+		*seed = strings.ToUpper(hex.EncodeToString(random))
+		fmt.Println("seed:", *seed)
+	}
+
+	for i := *index; i < *index+*count; i++ {
+		privateKey, address, err := nanoproto.DeriveAccount(*seed, uint32(i))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("index: %d\nprivate key: %s\naddress: %s\n", i, privateKey, address)
+	}
+
+	return nil
+}
+
+func runEstimate(args []string) error {
+	fs := flag.NewFlagSet("estimate", flag.ExitOnError)
+	file := fs.String("file", "-", "payload to estimate, - for stdin")
+	size := fs.Int("size", -1, "payload size in bytes, instead of reading a file")
+	representative := fs.String("representative", "", "count the block changing back to this representative, like put")
+	carrier := carrierFlag(fs)
+	fs.Parse(args)
+
+	n := *size
+	if n < 0 {
+		data, err := readInput(*file)
+		if err != nil {
+			return err
+		}
+		n = len(data)
+	}
+
+	c, err := carrier()
+	if err != nil {
+		return err
+	}
+
+	// The account only selects the carrier, any address will do
+	address := nanoproto.BurnAddress
+	storage := nanoproto.NewNanoDataStorage(nil, &address, nil)
+	storage.SetCarrier(address, c)
+	if err := storage.SetRepresentative(*representative); err != nil {
+		return err
+	}
+
+	blocks := storage.EstimateBlocks(n)
+	if blocks < 0 {
+		return errors.New("invalid size")
+	}
+
+	frameBlocks := nanoproto.EstimateBlocks(n)
+
+	fmt.Printf("payload: %d bytes\nblocks: %d %s blocks (%d work generations, %d process calls)\nstored: %d bytes including framing and padding\n",
+		n, blocks, c.Subtype(), blocks, blocks, frameBlocks*32)
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-size", "12"}, "blocks: 1 change blocks"},
+		{[]string{"-size", "13"}, "blocks: 2 change blocks"},
+		{[]string{"-size", "100", "-representative", "nano_1111111111111111111111111111111111111111111111111111hifc8npp"}, "blocks: 5 change blocks"},
+		{[]string{"-size", "100", "-carrier", "link", "-representative", "nano_1111111111111111111111111111111111111111111111111111hifc8npp"}, "blocks: 4 send blocks"},
+	} {
+		output, err := run(t, runEstimate, test.args...)
+		if err != nil || !strings.Contains(output, test.want) {
+			t.Errorf("%q: got %q, %v, want %q", test.args, output, err, test.want)
+		}
+	}
+
+	if _, err := run(t, runEstimate, "-size", "1", "-representative", "nano_invalid"); err == nil {
+		t.Error("accepted an invalid representative")
+	}
+	if output, err := run(t, runEstimate, "-file", writeFile(t, "payload", make([]byte, 13))); err != nil || !strings.Contains(output, "payload: 13 bytes") {
+		t.Errorf("got %q, %v for a file", output, err)
+	}
+}
+
+func TestKeygen(t *testing.T) {
+	output, err := run(t, runKeygen, "-seed", strings.Repeat("5E", 32), "-index", "1", "-count", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(output, "seed:") || strings.Count(output, "address: nano_") != 2 || !strings.Contains(output, "index: 2") {
+		t.Errorf("printed %q", output)
+	}
+
+	if output, err := run(t, runKeygen); err != nil || !strings.HasPrefix(output, "seed: ") {
+		t.Errorf("got %q, %v without a seed", output, err)
+	}
+}
@@ -0,0 +1,162 @@
+// Command nanoproto stores and reads data on the NANO network from the command line.
+//
+// The node URL and keys can be given as flags or through the NANOPROTO_NODE, NANOPROTO_ADDRESS,
+// NANOPROTO_KEY and NANOPROTO_SEED environment variables.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/2xxn/go-nanoproto"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"put", "store a file or stdin on an account", runPut},
+	{"get", "print the messages stored on an account", runGet},
+	{"inspect", "show the chunks, frames and blocks of an account", runInspect},
+	{"keygen", "generate a seed or derive accounts from one", runKeygen},
+	{"estimate", "estimate how many blocks a payload needs", runEstimate},
+	{"plan", "build unsigned change blocks for a payload", runPlan},
+	{"sign", "sign a planned bundle offline", runSign},
+	{"broadcast", "attach work to a signed bundle and publish it", runBroadcast},
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != flag.Arg(0) {
+			continue
+		}
+
+		if err := cmd.run(flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, "nanoproto:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "nanoproto: unknown command %q\n", flag.Arg(0))
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: nanoproto <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "run nanoproto <command> -h for the flags of a command")
+}
+
+// env returns the environment variable or def when unset.
+func env(name, def string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return def
+}
+
+// nodeFlag registers the -node flag shared by every command talking to a node.
+func nodeFlag(fs *flag.FlagSet) *string {
+	return fs.String("node", env("NANOPROTO_NODE", ""), "node RPC URL (NANOPROTO_NODE)")
+}
+
+func newRPC(node string) (*nanoproto.RPC, error) {
+	if node == "" {
+		return nil, errors.New("no node URL, set -node or NANOPROTO_NODE")
+	}
+	return nanoproto.NewRPC(node), nil
+}
+
+// carrierFlag registers the -carrier and -amount flags selecting how data is stored in blocks.
+func carrierFlag(fs *flag.FlagSet) func() (nanoproto.Carrier, error) {
+	name := fs.String("carrier", "representative", "data carrier: representative (change blocks) or link (send blocks)")
+	amount := fs.String("amount", "1", "raw sent per block by the link carrier")
+
+	return func() (nanoproto.Carrier, error) {
+		switch *name {
+		case "representative":
+			return nanoproto.RepresentativeCarrier{}, nil
+		case "link":
+			raw, err := nanoproto.ParseRaw(*amount)
+			if err != nil {
+				return nil, err
+			}
+			return nanoproto.LinkCarrier{Amount: raw}, nil
+		}
+		return nil, fmt.Errorf("unknown carrier %q", *name)
+	}
+}
+
+// keyFlags holds the flags selecting the signing account: a private key, or a seed and index.
+type keyFlags struct {
+	address *string
+	key     *string
+	seed    *string
+	index   *uint
+}
+
+func addKeyFlags(fs *flag.FlagSet) keyFlags {
+	return keyFlags{
+		address: fs.String("address", env("NANOPROTO_ADDRESS", ""), "account address, derived from the key when empty (NANOPROTO_ADDRESS)"),
+		key:     fs.String("key", env("NANOPROTO_KEY", ""), "hex private key (NANOPROTO_KEY)"),
+		seed:    fs.String("seed", env("NANOPROTO_SEED", ""), "hex wallet seed, used when no key is given (NANOPROTO_SEED)"),
+		index:   fs.Uint("index", 0, "account index derived from the seed"),
+	}
+}
+
+// account resolves the flags into a private key and address.
+func (k keyFlags) account() (string, string, error) {
+	privateKey := *k.key
+
+	if privateKey == "" {
+		if *k.seed == "" {
+			return "", "", errors.New("no key, set -key/NANOPROTO_KEY or -seed/NANOPROTO_SEED")
+		}
+
+		var err error
+		privateKey, err = nanoproto.DeriveKey(*k.seed, uint32(*k.index))
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	address, err := nanoproto.PrivateKeyToAddress(privateKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	if *k.address != "" && *k.address != address {
+		return "", "", fmt.Errorf("key belongs to %s, not %s", address, *k.address)
+	}
+
+	return privateKey, address, nil
+}
+
+// readInput reads the named file, or stdin for "" and "-".
+func readInput(name string) ([]byte, error) {
+	if name == "" || name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
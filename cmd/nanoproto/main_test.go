@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"testing"
+)
+
+// run runs a command and returns what it printed on stdout.
+func run(t *testing.T, command func([]string) error, args ...string) (string, error) {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- data
+	}()
+
+	err = command(args)
+	writer.Close()
+
+	return string(<-output), err
+}
+
+// writeFile writes data to a file in a temporary directory and returns its path.
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+
+	path := t.TempDir() + "/" + name
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestCarrierFlag(t *testing.T) {
+	for _, test := range []struct {
+		args    []string
+		subtype string
+	}{
+		{nil, "change"},
+		{[]string{"-carrier", "link", "-amount", "5"}, "send"},
+		{[]string{"-carrier", "link", "-amount", "five"}, ""},
+		{[]string{"-carrier", "balance"}, ""},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		carrier := carrierFlag(fs)
+		fs.Parse(test.args)
+
+		c, err := carrier()
+		if test.subtype == "" {
+			if err == nil {
+				t.Errorf("%q: accepted", test.args)
+			}
+			continue
+		}
+		if err != nil || c.Subtype() != test.subtype {
+			t.Errorf("%q: got %v, %v, want a %s carrier", test.args, c, err, test.subtype)
+		}
+	}
+}
+
+func TestKeyFlags(t *testing.T) {
+	for _, name := range []string{"NANOPROTO_ADDRESS", "NANOPROTO_KEY", "NANOPROTO_SEED"} {
+		t.Setenv(name, "")
+	}
+
+	seed := bytes.Repeat([]byte("5E"), 32)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	keys := addKeyFlags(fs)
+	fs.Parse([]string{"-seed", string(seed), "-index", "2"})
+
+	privateKey, address, err := keys.account()
+	if err != nil || privateKey == "" || address == "" {
+		t.Fatalf("got %q, %q, %v", privateKey, address, err)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	keys = addKeyFlags(fs)
+	fs.Parse([]string{"-key", privateKey, "-address", "nano_1111111111111111111111111111111111111111111111111111hifc8npp"})
+
+	if _, _, err := keys.account(); err == nil {
+		t.Error("accepted a key for another address")
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	keys = addKeyFlags(fs)
+	fs.Parse(nil)
+
+	if _, _, err := keys.account(); err == nil {
+		t.Error("resolved an account without a key or seed")
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/2xxn/go-nanoproto"
+)
+
+// bundle is the JSON document passed between plan, sign and broadcast.
+type bundle struct {
+	Account  string                 `json:"account"`
+	Frontier string                 `json:"frontier"`
+	Balance  nanoproto.Raw          `json:"balance"`
+	Blocks   []nanoproto.StateBlock `json:"blocks"`
+	Hashes   []string               `json:"hashes"`
+}
+
+func readBundle(name string) (*bundle, error) {
+	data, err := readInput(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %v", err)
+	}
+
+	if len(b.Blocks) != len(b.Hashes) {
+		return nil, errors.New("invalid bundle: every block needs a hash")
+	}
+
+	return &b, b.verifyLinkage()
+}
+
+func writeBundle(b *bundle) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(b)
+}
+
+// verifyLinkage checks that every block belongs to the account, builds on the previous one and matches its recorded hash.
+func (b *bundle) verifyLinkage() error {
+	previous := b.Frontier
+
+	for i, block := range b.Blocks {
+		if block.Account != b.Account {
+			return fmt.Errorf("block %d belongs to %s, not %s", i, block.Account, b.Account)
+		}
+		if !strings.EqualFold(block.Previous, previous) {
+			return fmt.Errorf("block %d doesn't build on %s", i, previous)
+		}
+
+		hash, err := block.Hash()
+		if err != nil {
+			return fmt.Errorf("block %d: %v", i, err)
+		}
+		if !strings.EqualFold(hash, b.Hashes[i]) {
+			return fmt.Errorf("block %d hashes to %s, bundle says %s", i, hash, b.Hashes[i])
+		}
+
+		previous = hash
+	}
+
+	return nil
+}
+
+func runPlan(args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	node := nodeFlag(fs)
+	account := fs.String("address", env("NANOPROTO_ADDRESS", ""), "account that will store the payload (NANOPROTO_ADDRESS)")
+	frontier := fs.String("frontier", "", "current frontier of the account, fetched from the node when empty")
+	balance := fs.String("balance", "", "current balance of the account in raw, fetched from the node when empty")
+	file := fs.String("file", "-", "payload to store, - for stdin")
+	fs.Parse(args)
+
+	if *account == "" {
+		return errors.New("no account, set -address or NANOPROTO_ADDRESS")
+	}
+
+	if *frontier == "" || *balance == "" {
+		rpc, err := newRPC(*node)
+		if err != nil {
+			return fmt.Errorf("%v (or pass -frontier and -balance)", err)
+		}
+
+		info, err := rpc.AccountInfo(*account)
+		if err != nil {
+			return err
+		}
+
+		if *frontier == "" {
+			*frontier = info.Frontier
+		}
+		if *balance == "" {
+			*balance = info.Balance.String()
+		}
+	}
+
+	data, err := readInput(*file)
+	if err != nil {
+		return err
+	}
+
+	accountBalance, err := nanoproto.ParseRaw(*balance)
+	if err != nil {
+		return err
+	}
+
+	blocks, err := nanoproto.PlanMessage(*account, *frontier, accountBalance, data)
+	if err != nil {
+		return err
+	}
+
+	b := &bundle{Account: *account, Frontier: *frontier, Balance: accountBalance, Blocks: blocks}
+	for _, block := range blocks {
+		hash, _ := block.Hash()
+		b.Hashes = append(b.Hashes, hash)
+	}
+
+	return writeBundle(b)
+}
+
+func runSign(args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	bundleFile := fs.String("bundle", "-", "unsigned bundle, - for stdin")
+	seedFile := fs.String("seed-file", "", "file holding the hex wallet seed")
+	keyFile := fs.String("key-file", "", "file holding the hex private key, instead of a seed")
+	index := fs.Uint("index", 0, "account index derived from the seed")
+	fs.Parse(args)
+
+	b, err := readBundle(*bundleFile)
+	if err != nil {
+		return err
+	}
+
+	var privateKey string
+
+	switch {
+	case *keyFile != "":
+		key, err := os.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		privateKey = strings.TrimSpace(string(key))
+	case *seedFile != "":
+		seed, err := os.ReadFile(*seedFile)
+		if err != nil {
+			return err
+		}
+		privateKey, err = nanoproto.DeriveKey(strings.TrimSpace(string(seed)), uint32(*index))
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("set -seed-file or -key-file")
+	}
+
+	for i := range b.Blocks {
+		if err := b.Blocks[i].Sign(privateKey); err != nil {
+			return fmt.Errorf("block %d: %v", i, err)
+		}
+	}
+
+	return writeBundle(b)
+}
+
+func runBroadcast(args []string) error {
+	fs := flag.NewFlagSet("broadcast", flag.ExitOnError)
+	node := nodeFlag(fs)
+	bundleFile := fs.String("bundle", "-", "signed bundle, - for stdin")
+	fs.Parse(args)
+
+	rpc, err := newRPC(*node)
+	if err != nil {
+		return err
+	}
+
+	b, err := readBundle(*bundleFile)
+	if err != nil {
+		return err
+	}
+
+	for i, block := range b.Blocks {
+		if err := block.VerifySignature(); err != nil {
+			return fmt.Errorf("block %d: %v", i, err)
+		}
+	}
+
+	info, err := rpc.AccountInfo(b.Account)
+	if err != nil {
+		return err
+	}
+
+	// Resume after the last block the node already has, a broadcast may have been interrupted
+	start := -1
+	for i, hash := range b.Hashes {
+		if strings.EqualFold(hash, info.Frontier) {
+			start = i + 1
+		}
+	}
+	if strings.EqualFold(info.Frontier, b.Frontier) {
+		start = 0
+	}
+	if start < 0 {
+		return fmt.Errorf("account frontier %s doesn't match the bundle, plan again", info.Frontier)
+	}
+
+	for i := start; i < len(b.Blocks); i++ {
+		block := b.Blocks[i]
+
+		work, err := rpc.WorkGenerate(block.Previous)
+		if err != nil {
+			return fmt.Errorf("block %d: %v", i, err)
+		}
+		block.Work = work
+
+		hash, err := rpc.ProcessChangeRepBlock(block.Map())
+		if err != nil {
+			return fmt.Errorf("block %d: %v", i, err)
+		}
+		if !strings.EqualFold(hash, b.Hashes[i]) {
+			return fmt.Errorf("block %d: node returned hash %s, expected %s", i, hash, b.Hashes[i])
+		}
+
+		fmt.Println(hash)
+	}
+
+	fmt.Fprintf(os.Stderr, "broadcast %d of %d blocks\n", len(b.Blocks)-start, len(b.Blocks))
+	return nil
+}
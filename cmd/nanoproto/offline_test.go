@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/2xxn/go-nanoproto"
+)
+
+const testFrontier = "E0000000000000000000000000000000000000000000000000000000000000E0"
+
+var testData = bytes.Repeat([]byte("offline signing "), 6)
+
+// testBundle plans testData on the first account of the test seed.
+func testBundle(t *testing.T) (*bundle, string) {
+	t.Helper()
+
+	privateKey, address, err := nanoproto.DeriveAccount(strings.Repeat("5E", 32), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	balance, _ := nanoproto.ParseRaw("1000")
+	blocks, err := nanoproto.PlanMessage(address, testFrontier, balance, testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &bundle{Account: address, Frontier: testFrontier, Balance: balance, Blocks: blocks}
+	for _, block := range blocks {
+		hash, _ := block.Hash()
+		b.Hashes = append(b.Hashes, hash)
+	}
+
+	return b, privateKey
+}
+
+func TestVerifyLinkage(t *testing.T) {
+	_, other, _ := nanoproto.DeriveAccount(strings.Repeat("5E", 32), 1)
+
+	for _, test := range []struct {
+		name   string
+		tamper func(b *bundle)
+		want   string
+	}{
+		{"valid", func(b *bundle) {}, ""},
+		{"broken previous", func(b *bundle) { b.Blocks[2].Previous = b.Hashes[0] }, "block 2 doesn't build on"},
+		{"reordered blocks", func(b *bundle) {
+			b.Blocks[1], b.Blocks[2] = b.Blocks[2], b.Blocks[1]
+			b.Hashes[1], b.Hashes[2] = b.Hashes[2], b.Hashes[1]
+		}, "block 1 doesn't build on"},
+		{"wrong account", func(b *bundle) { b.Blocks[1].Account = other }, "block 1 belongs to"},
+		{"wrong bundle account", func(b *bundle) { b.Account = other }, "block 0 belongs to"},
+		{"wrong frontier", func(b *bundle) { b.Frontier = b.Hashes[0] }, "block 0 doesn't build on"},
+		{"changed chunk", func(b *bundle) { b.Blocks[1].Representative = other }, "block 1 hashes to"},
+	} {
+		b, _ := testBundle(t)
+		test.tamper(b)
+
+		err := b.verifyLinkage()
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got %v, want %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestReadBundleRejects(t *testing.T) {
+	b, _ := testBundle(t)
+	b.Hashes = b.Hashes[1:]
+
+	data, _ := json.Marshal(b)
+	if _, err := readBundle(writeFile(t, "bundle.json", data)); err == nil {
+		t.Error("read a bundle missing a hash")
+	}
+
+	if _, err := readBundle(writeFile(t, "bundle.json", []byte("{"))); err == nil {
+		t.Error("read a truncated bundle")
+	}
+}
+
+// broadcastNode answers the actions broadcast uses, with the account at frontier.
+func broadcastNode(t *testing.T, frontier string) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	var processed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Action string               `json:"action"`
+			Block  nanoproto.StateBlock `json:"block"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch request.Action {
+		case "account_info":
+			json.NewEncoder(w).Encode(map[string]string{"frontier": frontier, "balance": "1000"})
+		case "work_generate":
+			json.NewEncoder(w).Encode(map[string]string{"work": "0000000000000000"})
+		case "process":
+			hash, err := request.Block.Hash()
+			if err != nil || request.Block.VerifySignature() != nil || request.Block.Work == "" {
+				json.NewEncoder(w).Encode(map[string]string{"error": "Bad block"})
+				return
+			}
+			processed = append(processed, hash)
+			json.NewEncoder(w).Encode(map[string]string{"hash": hash})
+		default:
+			json.NewEncoder(w).Encode(map[string]string{"error": "Unknown command"})
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &processed
+}
+
+func TestPlanSignBroadcast(t *testing.T) {
+	t.Setenv("NANOPROTO_NODE", "")
+
+	want, privateKey := testBundle(t)
+
+	planned, err := run(t, runPlan, "-address", want.Account, "-frontier", testFrontier, "-balance", "1000", "-file", writeFile(t, "payload", testData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bundle
+	if err := json.Unmarshal([]byte(planned), &b); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Blocks) != nanoproto.EstimateBlocks(len(testData)) || strings.Join(b.Hashes, ",") != strings.Join(want.Hashes, ",") {
+		t.Fatalf("planned %d blocks with hashes %v", len(b.Blocks), b.Hashes)
+	}
+
+	signed, err := run(t, runSign, "-bundle", writeFile(t, "planned.json", []byte(planned)), "-key-file", writeFile(t, "key", []byte(privateKey+"\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal([]byte(signed), &b); err != nil {
+		t.Fatal(err)
+	}
+	for i, block := range b.Blocks {
+		if err := block.VerifySignature(); err != nil {
+			t.Fatalf("block %d: %v", i, err)
+		}
+	}
+	signedFile := writeFile(t, "signed.json", []byte(signed))
+
+	server, processed := broadcastNode(t, testFrontier)
+	output, err := run(t, runBroadcast, "-node", server.URL, "-bundle", signedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Fields(output); strings.Join(got, ",") != strings.Join(b.Hashes, ",") || len(*processed) != len(b.Blocks) {
+		t.Errorf("broadcast printed %v and published %d blocks", got, len(*processed))
+	}
+
+	// An interrupted broadcast resumes after the node's frontier
+	server, processed = broadcastNode(t, b.Hashes[1])
+	if _, err := run(t, runBroadcast, "-node", server.URL, "-bundle", signedFile); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(*processed, ",") != strings.Join(b.Hashes[2:], ",") {
+		t.Errorf("resumed broadcast published %v", *processed)
+	}
+
+	// A frontier outside the bundle means the account moved on
+	server, _ = broadcastNode(t, strings.Repeat("0", 63)+"1")
+	if _, err := run(t, runBroadcast, "-node", server.URL, "-bundle", signedFile); err == nil {
+		t.Error("broadcast a bundle planned on another frontier")
+	}
+}
+
+func TestSignBroadcastReject(t *testing.T) {
+	b, privateKey := testBundle(t)
+	keyFile := writeFile(t, "key", []byte(privateKey))
+
+	// Sign refuses a bundle whose blocks don't link up
+	broken := *b
+	broken.Blocks = append([]nanoproto.StateBlock(nil), b.Blocks...)
+	broken.Blocks[1].Previous = testFrontier
+	data, _ := json.Marshal(&broken)
+
+	if _, err := run(t, runSign, "-bundle", writeFile(t, "broken.json", data), "-key-file", keyFile); err == nil || !strings.Contains(err.Error(), "doesn't build on") {
+		t.Errorf("got %v, want a linkage error", err)
+	}
+
+	// Sign refuses a key of another account
+	data, _ = json.Marshal(b)
+	planned := writeFile(t, "planned.json", data)
+	seedFile := writeFile(t, "seed", []byte(strings.Repeat("5E", 32)))
+	if _, err := run(t, runSign, "-bundle", planned, "-seed-file", seedFile, "-index", "1"); err == nil {
+		t.Error("signed with the key of another account")
+	}
+
+	// Broadcast refuses blocks with an invalid signature
+	for i := range b.Blocks {
+		if err := b.Blocks[i].Sign(privateKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.Blocks[1].Signature = b.Blocks[0].Signature
+	data, _ = json.Marshal(b)
+
+	server, processed := broadcastNode(t, testFrontier)
+	if _, err := run(t, runBroadcast, "-node", server.URL, "-bundle", writeFile(t, "signed.json", data)); err == nil || !strings.Contains(err.Error(), "block 1") {
+		t.Errorf("got %v, want block 1 rejected", err)
+	}
+	if len(*processed) != 0 {
+		t.Errorf("published %d blocks", len(*processed))
+	}
+
+	if _, err := run(t, runSign, "-bundle", planned); err == nil {
+		t.Error("signed without a key")
+	}
+}
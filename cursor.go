@@ -0,0 +1,83 @@
+package nanoproto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Cursor remembers how far an account has been read so the next read only returns new messages.
+type Cursor struct {
+	Account string
+	Hash    string // Last block read, empty to start from the open block
+	Height  uint64
+	Pending []AccountHistoryRepChange `json:",omitempty"` // Blocks of a message that wasn't complete yet
+}
+
+// GetDataSince returns the messages completed after blockHash (all messages when empty) and a cursor for the next read.
+// A message that started before blockHash is only returned when reading from a cursor that carried it.
+func (s *NanoDataStorage) GetDataSince(address *string, blockHash string) ([][]byte, *Cursor, error) {
+	messages, cursor, err := s.ReadSince(&Cursor{Account: *address, Hash: blockHash})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data := make([][]byte, 0, len(messages))
+	for _, message := range messages {
+		data = append(data, message.Data)
+	}
+
+	return data, cursor, nil
+}
+
+// ReadSince returns the messages completed after the cursor and the cursor to continue from.
+func (s *NanoDataStorage) ReadSince(cursor *Cursor) ([]Message, *Cursor, error) {
+	history, err := s.historySince(cursor.Account, cursor.Hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	next := &Cursor{Account: cursor.Account, Hash: cursor.Hash, Height: cursor.Height}
+	if len(history) > 0 {
+		last := history[len(history)-1]
+		next.Hash = last.Hash
+		next.Height, _ = strconv.ParseUint(last.Height, 10, 64)
+	}
+
+	carrier := s.carrier(cursor.Account)
+	blocks, stream := carried(carrier, append(append([]AccountHistoryRepChange(nil), cursor.Pending...), history...))
+
+	// Carry the blocks of an unfinished message over to the next read
+	if _, open := scanFrames(stream); open >= 0 {
+		next.Pending = blocks[open/32:]
+	}
+
+	messages, err := decodeMessages(carrier, cursor.Account, blocks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return messages, next, nil
+}
+
+// historySince returns the blocks of an account following hash, oldest first.
+// Reads go through s.history so verification, quorum and the cache apply to incremental reads too.
+func (s *NanoDataStorage) historySince(address, hash string) ([]AccountHistoryRepChange, error) {
+	if s.cache == nil && !s.verify && s.quorum == nil {
+		// Nothing to check the blocks against, only fetch the new ones
+		return s.rpc.RawHistory(address, hash)
+	}
+
+	history, err := s.history(address)
+	if err != nil || hash == "" {
+		return history, err
+	}
+
+	for i, item := range history {
+		if strings.EqualFold(item.Hash, hash) {
+			return history[i+1:], nil
+		}
+	}
+
+	return nil, fmt.Errorf("block %s not found in the history of %s", hash, address)
+}
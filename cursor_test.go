@@ -0,0 +1,115 @@
+package nanoproto
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// Incremental reads must give the same results whether they fetch only the new blocks or go through the cache.
+func TestReadSinceCarriesUnfinishedMessage(t *testing.T) {
+	t.Run("direct", func(t *testing.T) { testReadSince(t, false) })
+	t.Run("cached", func(t *testing.T) { testReadSince(t, true) })
+}
+
+func testReadSince(t *testing.T, cached bool) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+	writer := NewNanoDataStorage(rpc, &address, &privateKey)
+
+	for _, data := range [][]byte{[]byte("first message"), testPayload} {
+		if err := writer.PutData(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only show the reader the chain up to the middle of the second message, as if it was still being written
+	full := node.chain(address)
+	visible := len(full) - 3
+	node.hook = func(action string, request map[string]interface{}) (interface{}, bool) {
+		if action != "account_history" || visible == 0 {
+			return nil, false
+		}
+
+		chain := node.chains[address]
+		node.chains[address] = chain[:visible]
+		defer func() { node.chains[address] = chain }()
+
+		return node.history(request), true
+	}
+
+	reader := NewNanoDataStorage(rpc, nil, nil)
+	if cached {
+		reader.SetHistoryCache(NewMemoryHistoryCache(4))
+	}
+
+	messages, cursor, err := reader.ReadSince(&Cursor{Account: address})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || string(messages[0].Data) != "first message" {
+		t.Fatalf("first read returned %d messages", len(messages))
+	}
+	if cursor.Hash != full[visible-1].Hash || len(cursor.Pending) == 0 {
+		t.Fatalf("cursor at %s with %d pending blocks, want %s and the start of the second message", cursor.Hash, len(cursor.Pending), full[visible-1].Hash)
+	}
+
+	// Cursors are meant to be persisted between reads
+	encoded, err := json.Marshal(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor = &Cursor{}
+	if err := json.Unmarshal(encoded, cursor); err != nil {
+		t.Fatal(err)
+	}
+
+	visible = 0
+
+	messages, cursor, err = reader.ReadSince(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || !bytes.Equal(messages[0].Data, testPayload) {
+		t.Fatalf("second read returned %d messages, want the carried-over one", len(messages))
+	}
+	if cursor.Hash != full[len(full)-1].Hash || len(cursor.Pending) != 0 {
+		t.Fatalf("cursor at %s with %d pending blocks after reading everything", cursor.Hash, len(cursor.Pending))
+	}
+
+	messages, cursor, err = reader.ReadSince(cursor)
+	if err != nil || len(messages) != 0 {
+		t.Fatalf("read without new blocks returned %d messages, %v", len(messages), err)
+	}
+
+	if err := writer.PutData([]byte("third message")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _, err := reader.GetDataSince(&address, cursor.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || string(data[0]) != "third message" {
+		t.Fatalf("GetDataSince returned %q", data)
+	}
+}
+
+// Without the carried blocks, a message that started before the cursor is not returned.
+func TestGetDataSinceMidMessage(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+
+	if err := NewNanoDataStorage(rpc, &address, &privateKey).PutData(testPayload); err != nil {
+		t.Fatal(err)
+	}
+
+	chain := node.chain(address)
+	data, cursor, err := NewNanoDataStorage(rpc, nil, nil).GetDataSince(&address, chain[2].Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 || cursor.Hash != chain[len(chain)-1].Hash {
+		t.Fatalf("GetDataSince returned %d messages, cursor at %s", len(data), cursor.Hash)
+	}
+}
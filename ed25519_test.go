@@ -0,0 +1,117 @@
+package nanoproto
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func signedBatch(t *testing.T, random *rand.Rand, n int) []BatchEntry {
+	t.Helper()
+	e := NewEd25519()
+	entries := make([]BatchEntry, n)
+
+	for i := range entries {
+		private := make([]byte, 32)
+		random.Read(private)
+
+		keys, err := e.GenerateKeys(hex.EncodeToString(private))
+		if err != nil {
+			t.Fatal(err)
+		}
+		public, _ := hex.DecodeString(keys["publicKey"])
+
+		message := make([]byte, random.Intn(100))
+		random.Read(message)
+
+		signature, err := e.Sign(message, private)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		entries[i] = BatchEntry{PublicKey: public, Message: message, Signature: signature}
+	}
+
+	return entries
+}
+
+func TestVerifyBatchValid(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	for _, n := range []int{1, 4, 5, 16, 33} {
+		if invalid := NewEd25519().VerifyBatch(signedBatch(t, random, n)); invalid != nil {
+			t.Errorf("%d valid signatures: VerifyBatch = %v", n, invalid)
+		}
+	}
+}
+
+func TestVerifyBatchEmpty(t *testing.T) {
+	if invalid := NewEd25519().VerifyBatch(nil); invalid != nil {
+		t.Fatalf("VerifyBatch(nil) = %v", invalid)
+	}
+	if invalid := NewEd25519().VerifyBatch([]BatchEntry{}); invalid != nil {
+		t.Fatalf("VerifyBatch(empty) = %v", invalid)
+	}
+}
+
+func TestVerifyBatchFindsInvalid(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+
+	for _, n := range []int{3, 8, 33} {
+		for _, bad := range []int{0, n / 2, n - 1} {
+			t.Run(fmt.Sprintf("%d/%d", bad, n), func(t *testing.T) {
+				entries := signedBatch(t, random, n)
+				entries[bad].Message = append(entries[bad].Message, 0)
+
+				if invalid := NewEd25519().VerifyBatch(entries); !reflect.DeepEqual(invalid, []int{bad}) {
+					t.Fatalf("VerifyBatch = %v, want [%d]", invalid, bad)
+				}
+			})
+		}
+	}
+
+	// Several bad entries across both halves, including malformed ones
+	entries := signedBatch(t, random, 20)
+	entries[1].Signature = entries[1].Signature[:63]
+	entries[7].PublicKey = entries[8].PublicKey
+	entries[12].Signature[40] ^= 1
+	entries[19].Signature = make([]byte, 64)
+
+	if invalid := NewEd25519().VerifyBatch(entries); !reflect.DeepEqual(invalid, []int{1, 7, 12, 19}) {
+		t.Fatalf("VerifyBatch = %v, want [1 7 12 19]", invalid)
+	}
+}
+
+// VerifyBatch must agree with Verify on every entry, whatever mix of valid and corrupted signatures it gets.
+func TestVerifyBatchMatchesVerify(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	e := NewEd25519()
+
+	for round := 0; round < 20; round++ {
+		entries := signedBatch(t, random, 1+random.Intn(40))
+
+		for i := range entries {
+			switch random.Intn(6) {
+			case 0:
+				entries[i].Signature[random.Intn(64)] ^= byte(1 << random.Intn(8))
+			case 1:
+				entries[i].PublicKey[random.Intn(32)] ^= byte(1 << random.Intn(8))
+			case 2:
+				random.Read(entries[i].Signature)
+			}
+		}
+
+		var want []int
+		for i, entry := range entries {
+			if !e.Verify(entry.Message, entry.PublicKey, entry.Signature) {
+				want = append(want, i)
+			}
+		}
+
+		if got := e.VerifyBatch(entries); !reflect.DeepEqual(got, want) {
+			t.Fatalf("round %d: VerifyBatch = %v, Verify rejects %v", round, got, want)
+		}
+	}
+}
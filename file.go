@@ -0,0 +1,167 @@
+package nanoproto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const DefaultSegmentSize = 4096
+
+// FileEncryption describes how the stored content was encrypted. nanoproto stores it verbatim and doesn't decrypt.
+type FileEncryption struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id,omitempty"`
+	Nonce     string `json:"nonce,omitempty"`
+}
+
+// FileSegment is one fixed-size piece of a file, stored as its own message and checked against its blake2b-256 hash.
+type FileSegment struct {
+	Ref  string `json:"ref"` // MessageRef of the message holding the segment
+	Hash string `json:"hash"`
+	Size int    `json:"size"`
+}
+
+type FileManifest struct {
+	Version    int             `json:"v"`
+	Name       string          `json:"name"`
+	Size       int             `json:"size"`
+	MIMEType   string          `json:"mime,omitempty"`
+	Hash       string          `json:"hash"`
+	Segments   []FileSegment   `json:"segments"`
+	Encryption *FileEncryption `json:"encryption,omitempty"`
+}
+
+type File struct {
+	FileManifest
+	Content []byte
+}
+
+type FileOptions struct {
+	Name        string
+	MIMEType    string // Detected from the content when empty
+	SegmentSize int    // DefaultSegmentSize when zero
+	Encryption  *FileEncryption
+}
+
+// contentHash returns the blake2b-256 hash of data in hex.
+func contentHash(data []byte) string {
+	hash := blake2b.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// PutFile stores content as segments followed by a manifest and returns the manifest's message reference.
+func (s *NanoDataStorage) PutFile(content []byte, opts FileOptions) (string, error) {
+	segmentSize := opts.SegmentSize
+	if segmentSize == 0 {
+		segmentSize = DefaultSegmentSize
+	}
+	if segmentSize < 0 {
+		return "", errors.New("segment size must be positive")
+	}
+
+	manifest := FileManifest{
+		Version:    1,
+		Name:       opts.Name,
+		Size:       len(content),
+		MIMEType:   opts.MIMEType,
+		Hash:       contentHash(content),
+		Segments:   []FileSegment{},
+		Encryption: opts.Encryption,
+	}
+
+	if manifest.MIMEType == "" {
+		manifest.MIMEType = http.DetectContentType(content)
+	}
+
+	written := map[string]string{} // Segment hash to reference
+
+	for _, segment := range chunks(&content, segmentSize) {
+		hash := contentHash(segment)
+
+		// Identical segments are only stored once
+		ref, ok := written[hash]
+		if !ok {
+			messageRef, err := s.putFramed(0, segment)
+			if err != nil {
+				return "", fmt.Errorf("failed to store segment %s: %v", hash, err)
+			}
+
+			ref = messageRef.String()
+			written[hash] = ref
+		}
+
+		manifest.Segments = append(manifest.Segments, FileSegment{ref, hash, len(segment)})
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	ref, err := s.putFramed(0, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to store manifest: %v", err)
+	}
+
+	return ref.String(), nil
+}
+
+// GetFile fetches the manifest at manifestRef and the segments it references, verifying every segment and the whole content.
+func (s *NanoDataStorage) GetFile(manifestRef string) (*File, error) {
+	ref, err := ParseMessageRef(manifestRef)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := s.GetMessage(ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest: %v", err)
+	}
+
+	var file File
+	if err := json.Unmarshal(message.Data, &file.FileManifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+
+	var content bytes.Buffer
+	fetched := map[string][]byte{}
+
+	for i, segment := range file.Segments {
+		data, ok := fetched[segment.Ref]
+		if !ok {
+			ref, err := ParseMessageRef(segment.Ref)
+			if err != nil {
+				return nil, fmt.Errorf("segment %d: %v", i, err)
+			}
+
+			message, err := s.GetMessage(ref)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch segment %d: %v", i, err)
+			}
+
+			data = message.Data
+			fetched[segment.Ref] = data
+		}
+
+		if len(data) != segment.Size || contentHash(data) != strings.ToLower(segment.Hash) {
+			return nil, fmt.Errorf("segment %d doesn't match the manifest", i)
+		}
+
+		content.Write(data)
+	}
+
+	file.Content = content.Bytes()
+
+	if len(file.Content) != file.Size || contentHash(file.Content) != strings.ToLower(file.Hash) {
+		return nil, errors.New("file content doesn't match the manifest")
+	}
+
+	return &file, nil
+}
@@ -0,0 +1,97 @@
+package nanoproto
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPutFileGetFile(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+
+	// The first and last segments are identical and stored once
+	segment := bytes.Repeat([]byte("a"), 40)
+	content := append(append(append([]byte{}, segment...), bytes.Repeat([]byte("b"), 40)...), segment...)
+	content = append(content, "tail"...)
+
+	ref, err := NewNanoDataStorage(rpc, &address, &privateKey).PutFile(content, FileOptions{Name: "test.txt", SegmentSize: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reading needs neither an address nor the rest of the account
+	file, err := NewNanoDataStorage(rpc, nil, nil).GetFile(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(file.Content, content) {
+		t.Fatalf("read %q", file.Content)
+	}
+	if file.Name != "test.txt" || file.Size != len(content) || !strings.HasPrefix(file.MIMEType, "text/plain") {
+		t.Errorf("manifest %+v", file.FileManifest)
+	}
+	if len(file.Segments) != 4 {
+		t.Fatalf("%d segments, want 4", len(file.Segments))
+	}
+	if file.Segments[0].Ref != file.Segments[2].Ref {
+		t.Error("identical segments were stored twice")
+	}
+	if file.Segments[0].Ref == file.Segments[1].Ref {
+		t.Error("different segments share a reference")
+	}
+	if messages, err := NewNanoDataStorage(rpc, nil, nil).GetDataRefs(&address); err != nil || len(messages) != 4 {
+		t.Errorf("account holds %d messages (%v), want 3 segments and the manifest", len(messages), err)
+	}
+}
+
+func TestPutFileEmpty(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+
+	ref, err := NewNanoDataStorage(rpc, &address, &privateKey).PutFile(nil, FileOptions{Name: "empty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := NewNanoDataStorage(rpc, nil, nil).GetFile(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Content) != 0 || len(file.Segments) != 0 {
+		t.Errorf("read %d bytes in %d segments", len(file.Content), len(file.Segments))
+	}
+}
+
+func TestGetFileInvalid(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+	storage := NewNanoDataStorage(rpc, &address, &privateKey)
+
+	if _, err := storage.GetFile("https://example.com/file"); err == nil {
+		t.Error("read a file from a reference without the nanoproto scheme")
+	}
+
+	// A segment that isn't a manifest
+	ref, err := storage.putFramed(0, []byte("not a manifest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.GetFile(ref.String()); err == nil || !strings.Contains(err.Error(), "invalid manifest") {
+		t.Errorf("got %v, want an invalid manifest error", err)
+	}
+
+	// A manifest pointing at the wrong segment
+	other, err := storage.putFramed(0, []byte("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := `{"v":1,"size":4,"hash":"` + contentHash([]byte("data")) + `","segments":[{"ref":"` + other.String() + `","hash":"` + contentHash([]byte("data")) + `","size":4}]}`
+	if ref, err = storage.putFramed(0, []byte(manifest)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.GetFile(ref.String()); err == nil || !strings.Contains(err.Error(), "doesn't match") {
+		t.Errorf("got %v, want a segment mismatch", err)
+	}
+}
@@ -0,0 +1,66 @@
+package nanoproto
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Invoker sends a request to the node, or on to the next interceptor.
+type Invoker func(request map[string]interface{}) ([]byte, error)
+
+// Interceptor wraps RPC.Call. It sees the action and request body, may change the request before calling next
+// (copy it first, callers may reuse it) and gets the raw response and error back, retries included.
+type Interceptor func(action string, request map[string]interface{}, next Invoker) ([]byte, error)
+
+// Use appends interceptors to the chain. The first one added is the outermost.
+func (r *RPC) Use(interceptors ...Interceptor) {
+	r.interceptors = append(r.interceptors, interceptors...)
+}
+
+// WithInterceptors sets the interceptor chain of a new client, see Use.
+func WithInterceptors(interceptors ...Interceptor) RPCOption {
+	return func(c *rpcConfig) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+// LoggingInterceptor logs every call with its action, duration and response size at debug level,
+// and failed calls with their error at warn level.
+func LoggingInterceptor(logger *slog.Logger) Interceptor {
+	return LoggingInterceptorContext(context.Background(), logger)
+}
+
+// LoggingInterceptorContext is LoggingInterceptor passing ctx to the log handler, for handlers that read
+// values such as trace IDs from it. RPC calls don't take a context of their own.
+func LoggingInterceptorContext(ctx context.Context, logger *slog.Logger) Interceptor {
+	return func(action string, request map[string]interface{}, next Invoker) ([]byte, error) {
+		start := time.Now()
+		resp, err := next(request)
+
+		attrs := []slog.Attr{
+			slog.String("action", action),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("response_bytes", len(resp)),
+		}
+
+		level := slog.LevelDebug
+		if err != nil {
+			level = slog.LevelWarn
+			attrs = append(attrs, slog.Any("error", err))
+		}
+
+		logger.LogAttrs(ctx, level, "rpc call", attrs...)
+		return resp, err
+	}
+}
+
+// TimingInterceptor reports how long every call took, e.g. to feed a metrics histogram.
+func TimingInterceptor(observe func(action string, duration time.Duration, err error)) Interceptor {
+	return func(action string, request map[string]interface{}, next Invoker) ([]byte, error) {
+		start := time.Now()
+		resp, err := next(request)
+		observe(action, time.Since(start), err)
+		return resp, err
+	}
+}
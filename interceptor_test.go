@@ -0,0 +1,78 @@
+package nanoproto
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestInterceptorSeesRewrittenAction(t *testing.T) {
+	var sent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+		sent, _ = request["action"].(string)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	var seen []string
+	record := func(action string, request map[string]interface{}, next Invoker) ([]byte, error) {
+		seen = append(seen, action)
+		return next(request)
+	}
+	rewrite := func(action string, request map[string]interface{}, next Invoker) ([]byte, error) {
+		seen = append(seen, action)
+		return next(map[string]interface{}{"action": "pending", "account": request["account"]})
+	}
+
+	rpc := NewRPC(server.URL, WithInterceptors(record, rewrite, record))
+	if _, err := rpc.Call(map[string]interface{}{"action": "receivable", "account": "nano_1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"receivable", "receivable", "pending"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("interceptors saw %v, want %v", seen, want)
+	}
+	if sent != "pending" {
+		t.Errorf("node got %q, want the rewritten action", sent)
+	}
+}
+
+type contextKey struct{}
+
+// contextHandler records the context value of every record it handles.
+type contextHandler struct {
+	slog.Handler
+	values *[]any
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	*h.values = append(*h.values, ctx.Value(contextKey{}))
+	return nil
+}
+
+func TestLoggingInterceptorContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":"Unknown command"}`))
+	}))
+	defer server.Close()
+
+	var values []any
+	logger := slog.New(contextHandler{slog.NewTextHandler(nil, &slog.HandlerOptions{Level: slog.LevelDebug}), &values})
+	ctx := context.WithValue(context.Background(), contextKey{}, "trace-1")
+
+	rpc := NewRPC(server.URL, WithInterceptors(LoggingInterceptorContext(ctx, logger)))
+	rpc.SetRetryPolicy(NoRetry())
+
+	if _, err := rpc.Call(map[string]interface{}{"action": "nope"}); !isNodeError(err, "Unknown command") {
+		t.Fatalf("Call = %v", err)
+	}
+	if !reflect.DeepEqual(values, []any{"trace-1"}) {
+		t.Fatalf("handler got context values %v", values)
+	}
+}
@@ -0,0 +1,57 @@
+package nanoproto
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// DeriveKey derives the private key at index from a 32-byte wallet seed, the same way Nano wallets do.
+func DeriveKey(seed string, index uint32) (string, error) {
+	seedBytes, err := hex.DecodeString(seed)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode seed: %v", err)
+	}
+	if len(seedBytes) != 32 {
+		return "", errors.New("seed must be 32 bytes")
+	}
+
+	indexBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(indexBytes, index)
+
+	hasher, _ := blake2b.New(32, nil)
+	hasher.Write(seedBytes)
+	hasher.Write(indexBytes)
+
+	return strings.ToUpper(hex.EncodeToString(hasher.Sum(nil))), nil
+}
+
+// PrivateKeyToAddress returns the nano_ address belonging to a private key.
+func PrivateKeyToAddress(privateKey string) (string, error) {
+	keys, err := NewEd25519().GenerateKeys(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	pubKey, _ := hex.DecodeString(keys["publicKey"])
+	return publicKeyToNanoAddress(pubKey)
+}
+
+// DeriveAccount derives the private key and address at index from a wallet seed.
+func DeriveAccount(seed string, index uint32) (string, string, error) {
+	privateKey, err := DeriveKey(seed, index)
+	if err != nil {
+		return "", "", err
+	}
+
+	address, err := PrivateKeyToAddress(privateKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	return privateKey, address, nil
+}
@@ -0,0 +1,170 @@
+package nanoproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var ErrKeyNotFound = errors.New("key not found")
+
+var kvMagic = []byte("NPKV")
+
+const (
+	kvVersion   = 1
+	kvOpPut     = 1
+	kvOpDelete  = 2
+	kvMaxKeyLen = 1024
+)
+
+// KV is a key-value store on top of the storage account. Every Put and Delete is written as its own message,
+// reads replay the account history and the record with the highest block height wins.
+type KV struct {
+	storage *NanoDataStorage
+	mu      sync.Mutex
+	index   map[string]kvEntry
+	cursor  *Cursor
+}
+
+type kvEntry struct {
+	value   []byte
+	height  uint64
+	deleted bool
+}
+
+// NewKV opens the store on the storage account, which needs an address even when only reading.
+func NewKV(storage *NanoDataStorage) (*KV, error) {
+	if storage.address == nil {
+		return nil, errors.New("storage has no account address")
+	}
+
+	return &KV{storage: storage, index: map[string]kvEntry{}, cursor: &Cursor{Account: *storage.address}}, nil
+}
+
+func (kv *KV) Put(key string, value []byte) error {
+	record, err := encodeKVRecord(kvOpPut, key, value)
+	if err != nil {
+		return err
+	}
+
+	return kv.storage.PutData(record)
+}
+
+// Delete writes a tombstone for key.
+func (kv *KV) Delete(key string) error {
+	record, err := encodeKVRecord(kvOpDelete, key, nil)
+	if err != nil {
+		return err
+	}
+
+	return kv.storage.PutData(record)
+}
+
+func (kv *KV) Get(key string) ([]byte, error) {
+	if err := kv.Refresh(); err != nil {
+		return nil, err
+	}
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	entry, ok := kv.index[key]
+	if !ok || entry.deleted {
+		return nil, ErrKeyNotFound
+	}
+
+	return slices.Clone(entry.value), nil
+}
+
+// List returns the live keys starting with prefix, sorted.
+func (kv *KV) List(prefix string) ([]string, error) {
+	if err := kv.Refresh(); err != nil {
+		return nil, err
+	}
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	var keys []string
+	for key, entry := range kv.index {
+		if !entry.deleted && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// Refresh replays the records written since the last refresh into the in-memory index.
+// Like every incremental read it honours the verification, quorum and cache settings of the storage.
+func (kv *KV) Refresh() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	messages, cursor, err := kv.storage.ReadSince(kv.cursor)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		kv.apply(message)
+	}
+
+	kv.cursor = cursor
+	return nil
+}
+
+// apply records a message in the index if it is a KV record newer than what the index holds for its key.
+func (kv *KV) apply(message Message) {
+	op, key, value, ok := decodeKVRecord(message.Data)
+	if !ok {
+		return // Some other kind of message
+	}
+
+	if current, exists := kv.index[key]; exists && current.height > message.Ref.LastHeight {
+		return
+	}
+
+	kv.index[key] = kvEntry{value, message.Ref.LastHeight, op == kvOpDelete}
+}
+
+// Record layout: magic, version, op, uvarint key length, key, value.
+func encodeKVRecord(op byte, key string, value []byte) ([]byte, error) {
+	if key == "" || len(key) > kvMaxKeyLen {
+		return nil, errors.New("key must be between 1 and 1024 bytes")
+	}
+
+	var buffer bytes.Buffer
+
+	buffer.Write(kvMagic)
+	buffer.WriteByte(kvVersion)
+	buffer.WriteByte(op)
+	buffer.Write(binary.AppendUvarint(nil, uint64(len(key))))
+	buffer.WriteString(key)
+	buffer.Write(value)
+
+	return buffer.Bytes(), nil
+}
+
+func decodeKVRecord(data []byte) (byte, string, []byte, bool) {
+	if len(data) < 6 || !bytes.Equal(data[:4], kvMagic) || data[4] != kvVersion {
+		return 0, "", nil, false
+	}
+
+	op := data[5]
+	if op != kvOpPut && op != kvOpDelete {
+		return 0, "", nil, false
+	}
+
+	keyLen, n := binary.Uvarint(data[6:])
+	if n <= 0 || keyLen == 0 || keyLen > uint64(len(data)-6-n) {
+		return 0, "", nil, false
+	}
+
+	rest := data[6+n:]
+	return op, string(rest[:keyLen]), rest[keyLen:], true
+}
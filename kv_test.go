@@ -0,0 +1,61 @@
+package nanoproto
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewKVNeedsAddress(t *testing.T) {
+	if _, err := NewKV(NewNanoDataStorage(nil, nil, nil)); err == nil {
+		t.Fatal("NewKV accepted a storage without an address")
+	}
+}
+
+func TestKV(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+
+	storage := NewNanoDataStorage(rpc, &address, &privateKey)
+	storage.SetHistoryCache(NewMemoryHistoryCache(4))
+
+	kv, err := NewKV(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, op := range []struct{ key, value string }{{"a/1", "one"}, {"a/2", "two"}, {"b", "bee"}, {"a/1", "uno"}} {
+		if err := kv.Put(op.key, []byte(op.value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := kv.Delete("a/2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := kv.Get("a/1"); err != nil || string(value) != "uno" {
+		t.Errorf("Get(a/1) = %q, %v, want the latest value", value, err)
+	}
+	if _, err := kv.Get("a/2"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Get(a/2) = %v, want ErrKeyNotFound after the delete", err)
+	}
+	if keys, err := kv.List("a/"); err != nil || !reflect.DeepEqual(keys, []string{"a/1"}) {
+		t.Errorf("List(a/) = %v, %v", keys, err)
+	}
+
+	// A second store on the same account picks up every record
+	reader, err := NewKV(NewNanoDataStorage(rpc, &address, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys, err := reader.List(""); err != nil || !reflect.DeepEqual(keys, []string{"a/1", "b"}) {
+		t.Errorf("List() = %v, %v", keys, err)
+	}
+
+	if err := kv.Put("c", []byte("sea")); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := reader.Get("c"); err != nil || string(value) != "sea" {
+		t.Errorf("Get(c) = %q, %v after a refresh", value, err)
+	}
+}
@@ -1,19 +1,39 @@
 package nanoproto
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/hex"
-	"fmt"
-	"slices"
 )
 
 const (
 	BEGIN_PROTOBUF = "626567696E6461746100"
+	BEGIN_HEADER   = "626567696E6865616400" // Like BEGIN_PROTOBUF, followed by a uvarint length and a frameHeader
 	FORCE_END      = "0000656E646461746100"
+	ABORT_FRAME    = "000061626F72746461746100" // Padded to a whole chunk, closes the frame of a failed write
 )
 
 func CreateMessage(data []byte) []string {
+	return createMessage(nil, data)
+}
+
+// createMessage frames data, with a header when it isn't nil, and splits it into addresses.
+func createMessage(header []byte, data []byte) []string {
+	var messages []string
+
+	for _, chunk := range frameChunks(header, data) {
+		nanoAddress, err := publicKeyToNanoAddress(chunk)
+		if err != nil {
+			panic(err)
+		}
+
+		messages = append(messages, nanoAddress)
+	}
+
+	return messages
+}
+
+// frameChunks frames data, with a header when it isn't nil, and splits it into 32-byte chunks.
+func frameChunks(header []byte, data []byte) [][]byte {
 	beginProtoMark, err := hex.DecodeString(BEGIN_PROTOBUF)
 	endProtoMark, err := hex.DecodeString(FORCE_END)
 	if err != nil {
@@ -22,7 +42,14 @@ func CreateMessage(data []byte) []string {
 
 	var buffer bytes.Buffer
 
+	if header != nil {
+		beginProtoMark, _ = hex.DecodeString(BEGIN_HEADER)
+	}
+
 	buffer.Write(beginProtoMark)
+	if header != nil {
+		buffer.Write(AppendBytes(nil, header))
+	}
 	buffer.Write(data)
 	buffer.Write(endProtoMark)
 
@@ -32,29 +59,36 @@ func CreateMessage(data []byte) []string {
 		bytes = append(bytes, make([]byte, padding)...)
 	}
 
-	var messages []string
+	var chunks [][]byte
 
 	for i := 0; i < len(bytes); i += 32 {
-		chunk := bytes[i : i+32]
-		nanoAddress, err := publicKeyToNanoAddress(chunk)
-		if err != nil {
-			panic(err)
-		}
-
-		messages = append(messages, nanoAddress)
+		chunks = append(chunks, bytes[i:i+32])
 	}
 
-	return messages
+	return chunks
 }
 
-func getBuffers(history []string) [][]byte {
-	beginProtoMark, err := hex.DecodeString(BEGIN_PROTOBUF)
-	endProtoMark, err := hex.DecodeString(FORCE_END)
-	if err != nil {
-		panic(err)
+// EstimateBlocks returns how many change blocks CreateMessage needs for a payload of size bytes.
+// NanoDataStorage.EstimateBlocks also counts the blocks its writes add.
+func EstimateBlocks(size int) int {
+	return estimateBlocks(nil, size)
+}
+
+// estimateBlocks returns how many chunks frameChunks makes of a payload of size bytes and the given header.
+func estimateBlocks(header []byte, size int) int {
+	if size < 0 {
+		return -1
 	}
 
-	var buffers [][]byte
+	framed := len(BEGIN_PROTOBUF)/2 + size + len(FORCE_END)/2
+	if header != nil {
+		framed += len(AppendBytes(nil, header))
+	}
+
+	return (framed + 31) / 32
+}
+
+func getBuffers(history []string) [][]byte {
 	var longChunk string = ""
 
 	for _, chunk := range history {
@@ -66,57 +100,111 @@ func getBuffers(history []string) [][]byte {
 		panic(err)
 	}
 
-	buffer := bytes.NewBuffer(longChunkBytes)
-	reader := bufio.NewReaderSize(buffer, len(longChunkBytes))
-	currentlyReading := false
-	var data []byte
+	var buffers [][]byte
 
-	for reader.Size() > 0 {
-		// If we are not currently reading a protobuf message, check for the beginning of a new one
-		if !currentlyReading {
-			_, err := reader.ReadBytes(beginProtoMark[0])
-			if err != nil {
-				break // No more 0x62 bytes
-			}
+	frames, _ := scanFrames(longChunkBytes)
 
-			reader.UnreadByte()
-			bytes, err := reader.Peek(len(beginProtoMark))
-			if err != nil {
-				fmt.Println("Error peeking bytes", err)
-				break
-			}
+	for _, f := range frames {
+		buffers = append(buffers, f.data)
+	}
 
-			if slices.Equal(bytes, beginProtoMark) {
-				currentlyReading = true
-				reader.Discard(len(beginProtoMark))
-				data = []byte{}
-			}
+	return buffers
+}
+
+// frame is a complete message found in the chunk stream, start and end are byte offsets including the marks.
+type frame struct {
+	data   []byte
+	header []byte // nil for frames started with BEGIN_PROTOBUF
+	start  int
+	end    int
+}
+
+// nextBegin returns the offset of the first begin mark at or after pos, and whether it starts a frame with a header.
+func nextBegin(stream []byte, pos int) (int, bool) {
+	beginProtoMark, _ := hex.DecodeString(BEGIN_PROTOBUF)
+	beginHeaderMark, _ := hex.DecodeString(BEGIN_HEADER)
+
+	plain := bytes.Index(stream[pos:], beginProtoMark)
+	headed := bytes.Index(stream[pos:], beginHeaderMark)
+
+	switch {
+	case headed >= 0 && (plain < 0 || headed < plain):
+		return pos + headed, true
+	case plain >= 0:
+		return pos + plain, false
+	}
+
+	return -1, false
+}
+
+// abortChunk returns the chunk RestoreRepresentative writes ahead of the restore block.
+func abortChunk() []byte {
+	chunk := make([]byte, 32)
+	mark, _ := hex.DecodeString(ABORT_FRAME)
+	copy(chunk, mark)
+	return chunk
+}
+
+// abortedAt returns the offset of the first abort chunk at or after pos, -1 when there is none.
+// Chunks start every 32 bytes of the stream, so the search stays on chunk boundaries.
+func abortedAt(stream []byte, pos int) int {
+	abort := abortChunk()
 
+	for i := (pos + 31) / 32 * 32; i+32 <= len(stream); i += 32 {
+		if bytes.Equal(stream[i:i+32], abort) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// scanFrames finds every complete begin/end framed message in the concatenated chunk bytes.
+// Bytes outside of frames are ignored, the offset of a trailing unterminated frame is returned (-1 when there is none).
+func scanFrames(stream []byte) ([]frame, int) {
+	endProtoMark, _ := hex.DecodeString(FORCE_END)
+
+	var frames []frame
+	pos := 0
+
+	for {
+		start, headed := nextBegin(stream, pos)
+		if start < 0 {
+			return frames, -1
+		}
+
+		dataStart := start + len(BEGIN_PROTOBUF)/2
+
+		end := bytes.Index(stream[dataStart:], endProtoMark)
+
+		limit := len(stream)
+		if end >= 0 {
+			limit = dataStart + end
+		}
+
+		// A failed write closes its frame with an abort chunk, skip the frame and the restore block after it
+		if abort := abortedAt(stream[:limit], dataStart); abort >= 0 {
+			pos = abort + 32
 			continue
 		}
 
-		// If we are currently reading a protobuf message, read until the end of the message
-		byteRead, err := reader.ReadByte()
-		if err != nil {
-			fmt.Println("Error reading byte", err)
-			break
+		if end < 0 {
+			return frames, start // Message isn't finished (yet)
 		}
 
-		if byteRead == endProtoMark[0] {
-			bytes, err := reader.Peek(len(endProtoMark) - 1)
+		pos = dataStart + end + len(endProtoMark)
+		f := frame{stream[dataStart : dataStart+end], nil, start, pos}
+
+		if headed {
+			header, n, err := ConsumeBytes(f.data)
 			if err != nil {
-				fmt.Println("Error peeking bytes", err)
-				break
+				continue // Malformed header
 			}
 
-			if slices.Equal(bytes, endProtoMark[1:]) {
-				currentlyReading = false
-				buffers = append(buffers, data)
-				continue
-			}
+			f.header = header
+			f.data = f.data[n:]
 		}
-		data = append(data, byteRead)
-	}
 
-	return buffers
+		frames = append(frames, f)
+	}
 }
@@ -0,0 +1,163 @@
+package nanoproto
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const messageRefScheme = "nanoproto://"
+
+// MessageRef locates a stored message on the ledger.
+type MessageRef struct {
+	Account     string
+	FirstHash   string // Block carrying the begin mark
+	LastHash    string // Block carrying the end mark
+	FirstHeight uint64
+	LastHeight  uint64
+	Timestamp   time.Time // Local timestamp of the last block, as reported by the node
+}
+
+// Message is a decoded message together with where it lives.
+type Message struct {
+	Data   []byte
+	Ref    MessageRef
+	Type   uint64 // Schema ID from the frame header, 0 for untyped messages
+	Signer string // Address of the verified author of a signed message, empty when unsigned
+}
+
+// frameHeader is carried by frames starting with BEGIN_HEADER.
+type frameHeader struct {
+	Type      uint64 `proto:"1,varint"`
+	Signer    []byte `proto:"2,bytes"` // Author public key
+	Signature []byte `proto:"3,bytes"`
+}
+
+// String encodes the reference as nanoproto://<account>/<firstHash>.
+func (r MessageRef) String() string {
+	return messageRefScheme + r.Account + "/" + r.FirstHash
+}
+
+// ParseMessageRef parses a nanoproto://<account>/<firstHash> reference. Only Account and FirstHash are set.
+func ParseMessageRef(ref string) (MessageRef, error) {
+	rest, ok := strings.CutPrefix(ref, messageRefScheme)
+	if !ok {
+		return MessageRef{}, errors.New("message reference must start with " + messageRefScheme)
+	}
+
+	account, hash, ok := strings.Cut(rest, "/")
+	if !ok || len(hash) != 64 {
+		return MessageRef{}, fmt.Errorf("invalid message reference: %s", ref)
+	}
+
+	if _, err := nanoAddressToPublicKey(account); err != nil {
+		return MessageRef{}, fmt.Errorf("invalid message reference account: %v", err)
+	}
+
+	return MessageRef{Account: account, FirstHash: strings.ToUpper(hash)}, nil
+}
+
+// GetDataRefs works like GetData but returns every message with its reference.
+func (s *NanoDataStorage) GetDataRefs(address *string) ([]Message, error) {
+	history, err := s.history(*address)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeMessages(s.carrier(*address), *address, history)
+}
+
+// messageBlocksPage is how many blocks GetMessage fetches at once when the end of the message isn't known.
+const messageBlocksPage = 64
+
+// GetMessage fetches only the blocks of the referenced message.
+func (s *NanoDataStorage) GetMessage(ref MessageRef) (*Message, error) {
+	count := messageBlocksPage
+	if ref.LastHeight >= ref.FirstHeight && ref.FirstHeight > 0 {
+		count = int(ref.LastHeight-ref.FirstHeight) + 1
+	}
+
+	var history []AccountHistoryRepChange
+	head := ref.FirstHash
+	carrier := s.carrier(ref.Account)
+
+	for {
+		page, err := s.rpc.HistoryPage(ref.Account, head, count, true)
+		if err != nil {
+			return nil, err
+		}
+
+		if history == nil && (len(page.History) == 0 || !strings.EqualFold(page.History[0].Hash, ref.FirstHash)) {
+			return nil, fmt.Errorf("block %s not found in the history of %s", ref.FirstHash, ref.Account)
+		}
+
+		for _, item := range page.History {
+			if _, ok := carrier.Chunk(item); ok {
+				history = append(history, item)
+			}
+		}
+
+		messages, err := decodeMessages(carrier, ref.Account, history)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(messages) > 0 {
+			if !strings.EqualFold(messages[0].Ref.FirstHash, ref.FirstHash) {
+				return nil, fmt.Errorf("no message starts at block %s", ref.FirstHash)
+			}
+
+			return &messages[0], nil
+		}
+
+		if page.Next == "" {
+			return nil, fmt.Errorf("message at block %s is incomplete", ref.FirstHash)
+		}
+
+		head = page.Next
+		count = messageBlocksPage
+	}
+}
+
+// decodeMessages decodes the framed messages carried by a history (oldest first) and maps them to their blocks.
+func decodeMessages(carrier Carrier, account string, history []AccountHistoryRepChange) ([]Message, error) {
+	history, stream := carried(carrier, history)
+
+	var messages []Message
+
+	frames, _ := scanFrames(stream)
+
+	for _, f := range frames {
+		var header frameHeader
+		if f.header != nil && Unmarshal(f.header, &header) != nil {
+			continue // Malformed header
+		}
+
+		signer, ok := header.verify(account, f.data)
+		if !ok {
+			continue // Claims an author that didn't sign it
+		}
+
+		first := history[f.start/32]
+		last := history[(f.end-1)/32]
+
+		ref := MessageRef{
+			Account:   account,
+			FirstHash: first.Hash,
+			LastHash:  last.Hash,
+		}
+
+		ref.FirstHeight, _ = strconv.ParseUint(first.Height, 10, 64)
+		ref.LastHeight, _ = strconv.ParseUint(last.Height, 10, 64)
+
+		if timestamp, err := strconv.ParseInt(last.LocalTimestamp, 10, 64); err == nil {
+			ref.Timestamp = time.Unix(timestamp, 0)
+		}
+
+		messages = append(messages, Message{f.data, ref, header.Type, signer})
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,61 @@
+package nanoproto
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// BurnAddress is the address of the all-zero public key.
+const BurnAddress = "nano_1111111111111111111111111111111111111111111111111111hifc8npp"
+
+// messageBlockHash follows the off-chain message signing convention used by Nault: the message is hashed with
+// blake2b-256 and placed in the link of a dummy state block (previous zero, burn representative, zero balance)
+// whose hash is what gets signed. Such a block can never be valid on the ledger.
+func messageBlockHash(address, msg string) ([]byte, error) {
+	msgHash := blake2b.Sum256([]byte(msg))
+	return stateBlockHash(address, ZeroHash, BurnAddress, Raw{}, hex.EncodeToString(msgHash[:]))
+}
+
+// SignMessage signs arbitrary text with a Nano private key and returns the hex signature.
+func SignMessage(privateKey, msg string) (string, error) {
+	address, err := PrivateKeyToAddress(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := messageBlockHash(address, msg)
+	if err != nil {
+		return "", err
+	}
+
+	privKeyBytes, _ := hex.DecodeString(privateKey)
+	signature, err := NewEd25519().Sign(hash, privKeyBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToUpper(hex.EncodeToString(signature)), nil
+}
+
+// VerifyMessage checks a signature made by SignMessage (or a compatible wallet) for address.
+func VerifyMessage(address, msg, signature string) bool {
+	pubKey, err := nanoAddressToPublicKey(address)
+	if err != nil {
+		return false
+	}
+
+	hash, err := messageBlockHash(address, msg)
+	if err != nil {
+		return false
+	}
+
+	pubKeyBytes, _ := hex.DecodeString(pubKey)
+	signatureBytes, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	return NewEd25519().Verify(hash, pubKeyBytes, signatureBytes)
+}
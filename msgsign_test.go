@@ -0,0 +1,112 @@
+package nanoproto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+var messageVectors = []struct {
+	privateKey string
+	address    string
+	msg        string
+	signature  string
+}{
+	{
+		"0000000000000000000000000000000000000000000000000000000000000001",
+		"nano_3kdbxitaj7f6mrir6miiwtw4muhcc58e6tn5st6rfaxsdnb7gr4roudwn951",
+		"hello",
+		"768A2A5C9BAACD3B7D6C430958B1F7EDD89D487926C61029FF1ADE6C4C127C6C55A3708F5C517BA5F52B306D5103EF343B5335689A62C0259ADA9DD18BF1C904",
+	},
+	{
+		// Account 0 of the all-zero seed
+		"9F0E444C69F77A49BD0BE89DB92C38FE713E0963165CCA12FAF5712D7657120F",
+		"nano_3i1aq1cchnmbn9x5rsbap8b15akfh7wj7pwskuzi7ahz8oq6cobd99d4r3b7",
+		"Sign in to example.com, nonce 42",
+		"6BDBBC34BABC42DB28F56B7B10054B820CA03244CD89FCB9F0AADFD4A4CCC19DA32DE4A64D59CD6CE41D4EC7771CE6935825D30ED83C1A8FD0A150C410AB4309",
+	},
+}
+
+func TestSignMessageVectors(t *testing.T) {
+	for _, v := range messageVectors {
+		address, err := PrivateKeyToAddress(v.privateKey)
+		if err != nil || address != v.address {
+			t.Fatalf("address of %s = %s, %v, want %s", v.privateKey, address, err, v.address)
+		}
+
+		signature, err := SignMessage(v.privateKey, v.msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signature != v.signature {
+			t.Errorf("SignMessage(%q) = %s, want %s", v.msg, signature, v.signature)
+		}
+
+		if !VerifyMessage(v.address, v.msg, v.signature) {
+			t.Errorf("VerifyMessage(%q) rejected a valid signature", v.msg)
+		}
+		if !VerifyMessage(v.address, v.msg, strings.ToLower(v.signature)) {
+			t.Errorf("VerifyMessage(%q) rejected a lowercase signature", v.msg)
+		}
+	}
+}
+
+// The signed hash must be the hash of the dummy state block wallets build, assembled here field by field.
+func TestMessageBlockLayout(t *testing.T) {
+	v := messageVectors[1]
+
+	pubKey, _ := nanoAddressToPublicKey(v.address)
+	account, _ := hex.DecodeString(pubKey)
+	link := blake2b.Sum256([]byte(v.msg))
+
+	preamble := make([]byte, 32)
+	preamble[31] = 6
+
+	block := bytes.Join([][]byte{
+		preamble,
+		account,
+		make([]byte, 32), // previous
+		make([]byte, 32), // representative, the burn address
+		make([]byte, 16), // balance
+		link[:],
+	}, nil)
+	want := blake2b.Sum256(block)
+
+	got, err := messageBlockHash(v.address, v.msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want[:]) {
+		t.Fatalf("messageBlockHash = %X, want %X", got, want)
+	}
+}
+
+func TestVerifyMessageRejects(t *testing.T) {
+	v := messageVectors[0]
+	other := messageVectors[1]
+
+	// Flip one bit of the signature, keeping it valid hex
+	tamperedBytes, _ := hex.DecodeString(v.signature)
+	tamperedBytes[5] ^= 1
+	tampered := hex.EncodeToString(tamperedBytes)
+
+	cases := []struct {
+		name, address, msg, signature string
+	}{
+		{"tampered message", v.address, v.msg + "!", v.signature},
+		{"other key", other.address, v.msg, v.signature},
+		{"tampered signature", v.address, v.msg, tampered},
+		{"signature of another message", v.address, other.msg, v.signature},
+		{"invalid hex", v.address, v.msg, "zz"},
+		{"invalid address", "nano_1234", v.msg, v.signature},
+	}
+
+	for _, c := range cases {
+		if VerifyMessage(c.address, c.msg, c.signature) {
+			t.Errorf("%s: VerifyMessage accepted an invalid signature", c.name)
+		}
+	}
+}
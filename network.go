@@ -2,12 +2,15 @@ package nanoproto
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/blake2b"
 )
@@ -24,248 +27,444 @@ type AccountHistoryItem struct {
 }
 
 type AccountHistoryRepresentatives struct {
-	Account string                    `json:"account"`
-	History []AccountHistoryRepChange `json:"history"`
+	Account  string                    `json:"account"`
+	History  []AccountHistoryRepChange `json:"history"`
+	Previous string                    `json:"previous"` // Head of the next (older) page
+	Next     string                    `json:"next"`     // Head of the next page when reading in reverse
+}
+
+// UnmarshalJSON accepts the "history": "" nodes send for accounts without blocks as an empty page.
+func (h *AccountHistoryRepresentatives) UnmarshalJSON(data []byte) error {
+	type page AccountHistoryRepresentatives
+
+	var x struct {
+		page
+		History json.RawMessage `json:"history"`
+	}
+
+	if err := json.Unmarshal(data, &x); err != nil {
+		return err
+	}
+
+	*h = AccountHistoryRepresentatives(x.page)
+	h.History = nil
+
+	if emptyHistory(x.History) {
+		return nil
+	}
+
+	return json.Unmarshal(x.History, &h.History)
+}
+
+// UnmarshalJSON accepts the "history": "" nodes send for accounts without blocks as an empty history.
+func (h *AccountHistory) UnmarshalJSON(data []byte) error {
+	type history AccountHistory
+
+	var x struct {
+		history
+		History json.RawMessage `json:"history"`
+	}
+
+	if err := json.Unmarshal(data, &x); err != nil {
+		return err
+	}
+
+	*h = AccountHistory(x.history)
+	h.History = nil
+
+	if emptyHistory(x.History) {
+		return nil
+	}
+
+	return json.Unmarshal(x.History, &h.History)
+}
+
+func emptyHistory(raw json.RawMessage) bool {
+	return len(raw) == 0 || bytes.Equal(raw, []byte(`""`)) || bytes.Equal(raw, []byte("null"))
 }
 
 type AccountHistoryRepChange struct {
 	Type           string `json:"type"`
 	Subtype        string `json:"subtype"`
 	Representative string `json:"representative"`
+	Hash           string `json:"hash"`
+	Previous       string `json:"previous"`
+	Height         string `json:"height"`
+	LocalTimestamp string `json:"local_timestamp"`
+	Balance        Raw    `json:"balance"`
+	Link           string `json:"link"`
+	Signature      string `json:"signature"`
+	Work           string `json:"work"`
+	Confirmed      string `json:"confirmed"`
 }
 
 type AccountInfo struct {
 	Frontier                   string `json:"frontier"`
 	OpenBlock                  string `json:"open_block"`
 	RepresentativeBlock        string `json:"representative_block"`
-	Balance                    string `json:"balance"`
+	Balance                    Raw    `json:"balance"`
 	ModifiedTimestamp          string `json:"modified_timestamp"`
 	BlockCount                 string `json:"block_count"`
 	AccountVersion             string `json:"account_version"`
 	ConfirmationHeight         string `json:"confirmation_height"`
 	ConfirmationHeightFrontier string `json:"confirmation_height_frontier"`
+	Representative             string `json:"representative"`
 }
 
 type RPC struct {
-	url    string
-	client *http.Client
+	url     string
+	client  *http.Client
+	retry   RetryPolicy
+	header  http.Header
+	timeout time.Duration
+	limiter *RateLimiter
+
+	interceptors []Interceptor
 }
 
-func NewRPC(uri string) *RPC {
-	// proxyURL, _ := url.Parse("http://127.0.0.1:8888")
-	// proxy := http.ProxyURL(proxyURL)
-	// transport := &http.Transport{Proxy: proxy}
+// NewRPC creates a client for the node at uri, configured by options such as WithBearerToken or WithProxy.
+func NewRPC(uri string, options ...RPCOption) *RPC {
+	config := rpcConfig{header: http.Header{}}
+
+	for _, option := range options {
+		option(&config)
+	}
 
-	client := &http.Client{
-		// Transport: transport,
+	if config.header.Get("User-Agent") == "" {
+		config.header.Set("User-Agent", DefaultUserAgent)
 	}
 
-	return &RPC{uri, client}
+	return &RPC{uri, config.httpClient(), DefaultRetryPolicy(), config.header, config.timeout, config.limiter, config.interceptors}
 }
 
+func (r *RPC) SetRetryPolicy(policy RetryPolicy) {
+	r.retry = policy
+}
+
+// Call sends a request through the interceptor chain and returns the raw response.
 func (r *RPC) Call(data map[string]interface{}) ([]byte, error) {
+	invoke := Invoker(r.call)
+	for i := len(r.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := r.interceptors[i], invoke
+		invoke = func(request map[string]interface{}) ([]byte, error) {
+			// An outer interceptor may have rewritten the request, including its action
+			action, _ := request["action"].(string)
+			return interceptor(action, request, next)
+		}
+	}
+
+	return invoke(data)
+}
+
+// call sends a request to the node, retrying per the retry policy.
+func (r *RPC) call(data map[string]interface{}) ([]byte, error) {
 	obj, err := json.Marshal(data)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
+	action, _ := data["action"].(string)
+
+	for attempt := 1; ; attempt++ {
+		if r.limiter != nil {
+			r.limiter.Wait(action)
+		}
+
+		resp, err := r.post(obj)
+		if err == nil {
+			err = nodeError(action, resp)
+		}
+
+		// A node asking us to slow down is obeyed by every client sharing the limiter, retrying or not
+		var statusErr *StatusError
+		rateLimited := errors.As(err, &statusErr) && statusErr.RetryAfter > 0
+		if rateLimited && r.limiter != nil {
+			r.limiter.Pause(statusErr.RetryAfter)
+		}
+
+		if err == nil || attempt >= r.retry.MaxAttempts || !r.retry.retryable(err) {
+			return resp, err
+		}
+
+		// A single wait covers both, the limiter's pause is over by the time the next attempt asks it
+		delay := r.retry.backoff(attempt)
+		if rateLimited {
+			delay = max(delay, statusErr.RetryAfter)
+		}
+
+		time.Sleep(delay)
+	}
+}
+
+func (r *RPC) post(obj []byte) ([]byte, error) {
 	body := string(obj)
 	contentType := "application/json"
 
 	req, err := http.NewRequest("POST", r.url, bytes.NewBuffer([]byte(body)))
 
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
 
+	if r.timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), r.timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	for key, values := range r.header {
+		req.Header[key] = values
+	}
+
 	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Content-Length", fmt.Sprintf("%d", len(body)))
-	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:78.0) Gecko/20100101 Firefox/78.0")
 
 	resp, err := r.client.Do(req)
 
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", errTransport, err)
 	}
 	defer resp.Body.Close()
 
 	bytes, err := io.ReadAll(resp.Body)
 
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", errTransport, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return bytes, &StatusError{resp.StatusCode, bytes, retryAfter(resp.Header.Get("Retry-After"))}
 	}
 
 	return bytes, nil
 }
 
-func (r *RPC) WorkGenerate(hash string) (string, error) {
-	data := map[string]interface{}{
-		"action": "work_generate",
-		"hash":   hash,
+// nodeError extracts the {"error": "..."} field nodes use to report failures.
+func nodeError(action string, resp []byte) error {
+	var x struct {
+		Error string `json:"error"`
 	}
 
-	resp, err := r.Call(data)
+	if json.Unmarshal(resp, &x) != nil || x.Error == "" {
+		return nil
+	}
+
+	return &NodeError{action, x.Error}
+}
 
+// request calls the node and decodes the response into T. Every typed RPC wrapper goes through it.
+func request[T any](r *RPC, data map[string]interface{}) (T, error) {
+	var x T
+
+	resp, err := r.Call(data)
 	if err != nil {
-		return "", err
+		return x, err
+	}
+
+	if err := json.Unmarshal(resp, &x); err != nil {
+		return x, fmt.Errorf("%v: invalid response: %v", data["action"], err)
 	}
 
-	var x map[string]string
+	return x, nil
+}
 
-	json.Unmarshal(resp, &x)
+func (r *RPC) WorkGenerate(hash string) (string, error) {
+	x, err := request[struct {
+		Work string `json:"work"`
+	}](r, map[string]interface{}{
+		"action": "work_generate",
+		"hash":   hash,
+	})
 
-	return x["work"], nil
+	return x.Work, err
 }
 
 func (r *RPC) AccountInfo(address string) (AccountInfo, error) {
-	data := map[string]interface{}{
-		"action":  "account_info",
-		"account": address,
-	}
+	return request[AccountInfo](r, map[string]interface{}{
+		"action":         "account_info",
+		"account":        address,
+		"representative": "true",
+	})
+}
 
-	resp, err := r.Call(data)
+func (r *RPC) ChangeRepresentativeBlock(privateKey, address, representative, work, previous string, balance Raw) (map[string]interface{}, error) {
+	block := NewChangeBlock(address, previous, representative, balance)
 
-	if err != nil {
-		return AccountInfo{}, err
+	if err := block.Sign(privateKey); err != nil {
+		return nil, err
 	}
 
-	var x AccountInfo
-
-	json.Unmarshal(resp, &x)
+	block.Work = work
 
-	return x, nil
+	return block.Map(), nil
 }
 
-func (r *RPC) ChangeRepresentativeBlock(privateKey, address, representative, work, previous, balance string) (map[string]interface{}, error) {
+// stateBlockHash computes the BLAKE2b hash identifying a state block, which is also the message being signed.
+func stateBlockHash(address, previous, representative string, balance Raw, link string) ([]byte, error) {
 	accountPubHex, err := nanoAddressToPublicKey(address)
-	repPubHex, err := nanoAddressToPublicKey(representative)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert address to public key: %v", err)
 	}
 
-	accountPubBytes, err := hex.DecodeString(accountPubHex)
-	repPubBytes, err := hex.DecodeString(repPubHex)
-	prevBytes, err := hex.DecodeString(previous)
+	repPubHex, err := nanoAddressToPublicKey(representative)
 	if err != nil {
-		return nil, fmt.Errorf("invalid previous block hash hex: %v", err)
+		return nil, fmt.Errorf("failed to convert representative to public key: %v", err)
 	}
 
-	preamble := make([]byte, 32)
-	preamble[31] = 0x6
+	accountPubBytes, _ := hex.DecodeString(accountPubHex)
+	repPubBytes, _ := hex.DecodeString(repPubHex)
 
-	zeroLink := make([]byte, 32) // Link field is all zeroes for a change block
+	prevBytes, err := hex.DecodeString(previous)
+	if err != nil || len(prevBytes) != 32 {
+		return nil, fmt.Errorf("invalid previous block hash hex: %v", previous)
+	}
 
-	balanceBytes, err := convertBalanceToBytes(balance)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get account info: %v", err)
+	linkBytes, err := hex.DecodeString(link)
+	if err != nil || len(linkBytes) != 32 {
+		return nil, fmt.Errorf("invalid link hex: %v", link)
 	}
 
+	preamble := make([]byte, 32)
+	preamble[31] = 0x6
+
 	// Create the block
 	hashData := append(preamble, accountPubBytes...) // Account public key
 	hashData = append(hashData, prevBytes...)        // Previous block hash
 	hashData = append(hashData, repPubBytes...)      // New representative
-	hashData = append(hashData, balanceBytes...)     // Balance
-	hashData = append(hashData, zeroLink...)         // Link (zero for change block)
+	hashData = append(hashData, balance.Bytes()...)  // Balance
+	hashData = append(hashData, linkBytes...)        // Link (zero for change block)
 
 	// Hash the block data using BLAKE2b (32-byte digest)
 	hasher, _ := blake2b.New(32, nil)
 	hasher.Write(hashData)
-	blockHash := hasher.Sum(nil)
 
-	// Decode private key from hex and sign the block hash
-	ed := NewEd25519()
+	return hasher.Sum(nil), nil
+}
 
-	privKeyBytes, _ := hex.DecodeString(privateKey)
-	signature, _ := ed.Sign(blockHash, privKeyBytes)
+// hashBlock computes the hash of a block built by ChangeRepresentativeBlock.
+func hashBlock(block map[string]interface{}) (string, error) {
+	field := func(name string) string {
+		value, _ := block[name].(string)
+		return value
+	}
 
-	block := map[string]interface{}{
-		"type":           "state",
-		"account":        address,
-		"previous":       previous,
-		"representative": representative,
-		"balance":        balance, // Balance as string
-		"link":           "0000000000000000000000000000000000000000000000000000000000000000",
-		"signature":      hex.EncodeToString(signature),
-		"work":           work,
+	balance, err := ParseRaw(field("balance"))
+	if err != nil {
+		return "", fmt.Errorf("failed to convert balance: %v", err)
 	}
 
-	return block, nil
+	hash, err := stateBlockHash(field("account"), field("previous"), field("representative"), balance, field("link"))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToUpper(hex.EncodeToString(hash)), nil
 }
 
+// ProcessChangeRepBlock publishes a change block and returns its hash.
+// Signed blocks are deterministic, so a block the node already has ("Old block") is reported as success,
+// which makes resubmitting after a lost response safe.
 func (r *RPC) ProcessChangeRepBlock(block map[string]interface{}) (string, error) {
+	return r.ProcessBlock(block, "change")
+}
+
+// ProcessBlock publishes a state block of the given subtype (send, receive, change...) and returns its hash,
+// treating "Old block" as success like ProcessChangeRepBlock.
+func (r *RPC) ProcessBlock(block map[string]interface{}, subtype string) (string, error) {
 	data := map[string]interface{}{
 		"action":     "process",
 		"json_block": "true",
-		"subtype":    "change",
+		"subtype":    subtype,
 		"block":      block,
 	}
 
-	resp, err := r.Call(data)
+	x, err := request[struct {
+		Hash string `json:"hash"`
+	}](r, data)
 
-	// log.Println("Processing change rep block")
-	// log.Println(string(resp))
-	if err != nil {
-		return "", err
+	if isNodeError(err, "Old block") {
+		return hashBlock(block)
 	}
 
-	var x map[string]string
-
-	json.Unmarshal(resp, &x)
-
-	return x["hash"], nil
+	return x.Hash, err
 }
 
 func (r *RPC) GetAccountInfo(address string) (AccountInfo, error) {
-	data := map[string]interface{}{
+	return request[AccountInfo](r, map[string]interface{}{
 		"action":  "account_info",
 		"account": address,
-	}
-
-	resp, err := r.Call(data)
+	})
+}
 
+// History returns the change blocks of an account, oldest first.
+func (r *RPC) History(address string) ([]AccountHistoryRepChange, error) {
+	history, err := r.RawHistory(address, "")
 	if err != nil {
-		return AccountInfo{}, err
+		return []AccountHistoryRepChange{}, err
 	}
 
-	var x AccountInfo
+	var received []AccountHistoryRepChange
 
-	json.Unmarshal(resp, &x)
+	for _, item := range history {
+		if isChangeBlock(item) {
+			received = append(received, item)
+		}
+	}
 
-	return x, nil
+	return received, nil
 }
 
-func (r *RPC) History(address string) ([]AccountHistoryRepChange, error) {
+func isChangeBlock(item AccountHistoryRepChange) bool {
+	return item.Type == "change" || (item.Type == "state" && item.Subtype == "change")
+}
+
+// historyPageSize is how many blocks are requested per account_history call.
+const historyPageSize = 200
+
+// HistoryPage requests one page of raw account history starting at head (inclusive, the frontier or open block when empty).
+func (r *RPC) HistoryPage(address, head string, count int, reverse bool) (AccountHistoryRepresentatives, error) {
 	data := map[string]interface{}{
 		"action":  "account_history",
 		"account": address,
-		"count":   200,
+		"count":   count,
 		"raw":     true,
 	}
 
-	resp, err := r.Call(data)
-
-	if err != nil {
-		return []AccountHistoryRepChange{}, err
+	if head != "" {
+		data["head"] = head
+	}
+	if reverse {
+		data["reverse"] = true
 	}
 
-	var x AccountHistoryRepresentatives
+	return request[AccountHistoryRepresentatives](r, data)
+}
 
-	json.Unmarshal(resp, &x)
+// RawHistory returns every block of an account after the block since (from the open block when empty), oldest first.
+func (r *RPC) RawHistory(address, since string) ([]AccountHistoryRepChange, error) {
+	var history []AccountHistoryRepChange
+	head := since
 
-	var received []AccountHistoryRepChange
+	for {
+		page, err := r.HistoryPage(address, head, historyPageSize, true)
+		if err != nil {
+			return nil, err
+		}
 
-	for _, item := range x.History {
-		if item.Type == "change" || (item.Type == "state" && item.Subtype == "change") {
-			received = append(received, item)
+		for _, item := range page.History {
+			if since != "" && strings.EqualFold(item.Hash, since) {
+				continue // head is included in the page
+			}
+
+			history = append(history, item)
 		}
-	}
 
-	for i, j := 0, len(received)-1; i < j; i, j = i+1, j-1 {
-		received[i], received[j] = received[j], received[i]
-	}
+		if page.Next == "" || len(page.History) == 0 {
+			return history, nil
+		}
 
-	return received, nil
+		head = page.Next
+	}
 }
 
 func (r *RPC) Received(address string) ([]AccountHistoryItem, error) {
@@ -275,16 +474,11 @@ func (r *RPC) Received(address string) ([]AccountHistoryItem, error) {
 		"count":   200,
 	}
 
-	resp, err := r.Call(data)
-
+	x, err := request[AccountHistory](r, data)
 	if err != nil {
 		return []AccountHistoryItem{}, err
 	}
 
-	var x AccountHistory
-
-	json.Unmarshal(resp, &x)
-
 	var received []AccountHistoryItem
 
 	for _, item := range x.History {
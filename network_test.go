@@ -0,0 +1,71 @@
+package nanoproto
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHistoryPageEmptyHistory(t *testing.T) {
+	for _, body := range []string{
+		`{"account":"nano_1","history":""}`,
+		`{"account":"nano_1","history":null}`,
+		`{"account":"nano_1"}`,
+	} {
+		var page AccountHistoryRepresentatives
+		if err := json.Unmarshal([]byte(body), &page); err != nil {
+			t.Errorf("%s: %v", body, err)
+			continue
+		}
+		if page.Account != "nano_1" || len(page.History) != 0 {
+			t.Errorf("%s: decoded %+v, want an empty page", body, page)
+		}
+	}
+
+	var page AccountHistoryRepresentatives
+	body := `{"account":"nano_1","history":[{"type":"state","hash":"AB"}],"next":"CD"}`
+	if err := json.Unmarshal([]byte(body), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.History) != 1 || page.History[0].Hash != "AB" || page.Next != "CD" {
+		t.Errorf("decoded %+v", page)
+	}
+}
+
+func TestReceived(t *testing.T) {
+	body := `{"account":"nano_1","history":[
+		{"type":"receive","account":"nano_3","amount":"3"},
+		{"type":"send","account":"nano_9","amount":"9"},
+		{"type":"receive","account":"nano_2","amount":"2"}]}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	rpc := NewRPC(server.URL)
+
+	received, err := rpc.Received("nano_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 2 || received[0].Amount != "2" || received[1].Amount != "3" {
+		t.Fatalf("Received = %+v, want the receives oldest first", received)
+	}
+
+	body = `{"account":"nano_1","history":""}`
+	if received, err := rpc.Received("nano_1"); err != nil || len(received) != 0 {
+		t.Fatalf("Received = %+v, %v for an account without blocks", received, err)
+	}
+
+	body = `{"error":"Bad account number"}`
+	if _, err := rpc.Received("nano_1"); !isNodeError(err, "Bad account number") {
+		t.Fatalf("Received = %v, want the node error", err)
+	}
+
+	body = `{"history":`
+	if _, err := rpc.Received("nano_1"); err == nil {
+		t.Fatal("Received accepted a truncated response")
+	}
+}
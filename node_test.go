@@ -0,0 +1,256 @@
+package nanoproto
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testNode is an in-memory node answering the RPC actions the storage layer uses. Blocks are checked for
+// forks and gaps like a real node would, but signatures and work are not.
+type testNode struct {
+	mu       sync.Mutex
+	chains   map[string][]AccountHistoryRepChange
+	cemented map[string]int // Confirmation height per account, every block is cemented when absent
+	calls    map[string]int
+
+	// hook runs before each action with the lock held and may answer it instead of the node
+	hook func(action string, request map[string]interface{}) (interface{}, bool)
+}
+
+// newTestNode starts a node and returns it with a client that doesn't retry or wait.
+func newTestNode(t *testing.T) (*testNode, *RPC) {
+	t.Helper()
+
+	node := &testNode{chains: map[string][]AccountHistoryRepChange{}, cemented: map[string]int{}, calls: map[string]int{}}
+	server := httptest.NewServer(node)
+	t.Cleanup(server.Close)
+
+	rpc := NewRPC(server.URL)
+	rpc.SetRetryPolicy(NoRetry())
+
+	return node, rpc
+}
+
+// testAccount derives an account from a fixed seed and opens it on the node with 1 Nano.
+func (n *testNode) testAccount(t *testing.T, index uint32) (address, privateKey string) {
+	t.Helper()
+
+	privateKey, address, err := DeriveAccount(strings.Repeat("5E", 32), index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	balance, _ := ParseNano("1")
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.chains[address] = []AccountHistoryRepChange{{
+		Type:           "state",
+		Subtype:        "open",
+		Hash:           fmt.Sprintf("%064X", 0xE0000+index),
+		Previous:       ZeroHash,
+		Representative: address,
+		Balance:        balance,
+		Link:           ZeroHash,
+	}}
+
+	return address, privateKey
+}
+
+// compete appends a block nobody signed, like a competing write from another client. Call it from a hook.
+func (n *testNode) compete(address, hash string) {
+	chain := n.chains[address]
+	last := chain[len(chain)-1]
+	n.chains[address] = append(chain, AccountHistoryRepChange{Type: "state", Subtype: "change", Hash: hash, Previous: last.Hash, Representative: last.Representative, Balance: last.Balance, Link: ZeroHash})
+}
+
+func (n *testNode) chain(address string) []AccountHistoryRepChange {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return append([]AccountHistoryRepChange(nil), n.chains[address]...)
+}
+
+func (n *testNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	action, _ := request["action"].(string)
+	n.calls[action]++
+
+	if n.hook != nil {
+		if response, ok := n.hook(action, request); ok {
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(n.handle(action, request))
+}
+
+func (n *testNode) handle(action string, request map[string]interface{}) interface{} {
+	field := func(m map[string]interface{}, key string) string {
+		value, _ := m[key].(string)
+		return value
+	}
+
+	switch action {
+	case "account_info":
+		chain, ok := n.chains[field(request, "account")]
+		if !ok {
+			return map[string]string{"error": "Account not found"}
+		}
+
+		cemented, ok := n.cemented[field(request, "account")]
+		if !ok {
+			cemented = len(chain)
+		}
+
+		last := chain[len(chain)-1]
+		return map[string]string{
+			"frontier":                     last.Hash,
+			"open_block":                   chain[0].Hash,
+			"balance":                      last.Balance.String(),
+			"block_count":                  strconv.Itoa(len(chain)),
+			"confirmation_height":          strconv.Itoa(cemented),
+			"confirmation_height_frontier": chain[cemented-1].Hash,
+			"representative":               last.Representative,
+		}
+
+	case "work_generate":
+		return map[string]string{"work": "0000000000000000", "hash": field(request, "hash")}
+
+	case "process":
+		block, _ := request["block"].(map[string]interface{})
+		hash, err := hashBlock(block)
+		if err != nil {
+			return map[string]string{"error": err.Error()}
+		}
+
+		account := field(block, "account")
+		chain := n.chains[account]
+
+		for _, existing := range chain {
+			if strings.EqualFold(existing.Hash, hash) {
+				return map[string]string{"error": "Old block"}
+			}
+		}
+
+		if !strings.EqualFold(chain[len(chain)-1].Hash, field(block, "previous")) {
+			for _, existing := range chain {
+				if strings.EqualFold(existing.Hash, field(block, "previous")) {
+					return map[string]string{"error": "Fork"}
+				}
+			}
+			return map[string]string{"error": "Gap previous block"}
+		}
+
+		balance, _ := ParseRaw(field(block, "balance"))
+		n.chains[account] = append(chain, AccountHistoryRepChange{
+			Type:           "state",
+			Subtype:        field(request, "subtype"),
+			Hash:           hash,
+			Previous:       field(block, "previous"),
+			Representative: field(block, "representative"),
+			Balance:        balance,
+			Link:           field(block, "link"),
+			Signature:      field(block, "signature"),
+			Work:           field(block, "work"),
+		})
+		return map[string]string{"hash": hash}
+
+	case "account_history":
+		return n.history(request)
+
+	case "block_info":
+		for account, chain := range n.chains {
+			for i, block := range chain {
+				if !strings.EqualFold(block.Hash, field(request, "hash")) {
+					continue
+				}
+
+				cemented, ok := n.cemented[account]
+				if !ok {
+					cemented = len(chain)
+				}
+
+				return map[string]string{
+					"block_account": account,
+					"balance":       block.Balance.String(),
+					"height":        strconv.Itoa(i + 1),
+					"confirmed":     strconv.FormatBool(i < cemented),
+					"subtype":       block.Subtype,
+				}
+			}
+		}
+		return map[string]string{"error": "Block not found"}
+	}
+
+	return map[string]string{"error": "Unknown command"}
+}
+
+// history pages through an account like the node: newest first from head, or oldest first with reverse.
+func (n *testNode) history(request map[string]interface{}) interface{} {
+	account, _ := request["account"].(string)
+	chain := n.chains[account]
+
+	count := len(chain)
+	if c, ok := request["count"].(string); ok {
+		count, _ = strconv.Atoi(c)
+	} else if c, ok := request["count"].(float64); ok {
+		count = int(c)
+	}
+
+	reverse := request["reverse"] == true || request["reverse"] == "true"
+	step, i := -1, len(chain)-1
+	if reverse {
+		step, i = 1, 0
+	}
+
+	if head, _ := request["head"].(string); head != "" {
+		i = -1
+		for j, block := range chain {
+			if strings.EqualFold(block.Hash, head) {
+				i = j
+			}
+		}
+		if i < 0 {
+			return map[string]string{"error": "Block not found"}
+		}
+	}
+
+	page := AccountHistoryRepresentatives{Account: account}
+	for ; i >= 0 && i < len(chain) && len(page.History) < count; i += step {
+		block := chain[i]
+		block.Height = strconv.Itoa(i + 1)
+		block.Confirmed = "true"
+		page.History = append(page.History, block)
+	}
+
+	if i >= 0 && i < len(chain) {
+		if reverse {
+			page.Next = chain[i].Hash
+		} else {
+			page.Previous = chain[i].Hash
+		}
+	}
+
+	if len(page.History) == 0 {
+		return map[string]string{"account": account, "history": ""}
+	}
+
+	return page
+}
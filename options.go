@@ -0,0 +1,109 @@
+package nanoproto
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultUserAgent is sent unless WithUserAgent or a User-Agent header says otherwise.
+const DefaultUserAgent = "go-nanoproto"
+
+// RPCOption configures an RPC client created by NewRPC.
+type RPCOption func(*rpcConfig)
+
+type rpcConfig struct {
+	client    *http.Client
+	transport http.RoundTripper
+	proxy     *url.URL
+	header    http.Header
+	timeout   time.Duration
+	limiter   *RateLimiter
+
+	interceptors []Interceptor
+}
+
+// WithHTTPClient sends requests through client instead of a new http.Client.
+func WithHTTPClient(client *http.Client) RPCOption {
+	return func(c *rpcConfig) {
+		c.client = client
+	}
+}
+
+// WithTransport sends requests through transport, e.g. for tracing or tests.
+func WithTransport(transport http.RoundTripper) RPCOption {
+	return func(c *rpcConfig) {
+		c.transport = transport
+	}
+}
+
+// WithProxy routes requests through an HTTP proxy. It applies to the default transport,
+// or to the one given with WithTransport when that is an *http.Transport.
+func WithProxy(proxy *url.URL) RPCOption {
+	return func(c *rpcConfig) {
+		c.proxy = proxy
+	}
+}
+
+// WithHeader adds a header to every request.
+func WithHeader(key, value string) RPCOption {
+	return func(c *rpcConfig) {
+		c.header.Add(key, value)
+	}
+}
+
+// WithBearerToken authenticates with an Authorization: Bearer header.
+func WithBearerToken(token string) RPCOption {
+	return func(c *rpcConfig) {
+		c.header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// WithAPIKey sends key in the header the node provider expects it in.
+func WithAPIKey(header, key string) RPCOption {
+	return func(c *rpcConfig) {
+		c.header.Set(header, key)
+	}
+}
+
+func WithUserAgent(userAgent string) RPCOption {
+	return func(c *rpcConfig) {
+		c.header.Set("User-Agent", userAgent)
+	}
+}
+
+// WithTimeout bounds every single request, retries get a fresh timeout.
+func WithTimeout(timeout time.Duration) RPCOption {
+	return func(c *rpcConfig) {
+		c.timeout = timeout
+	}
+}
+
+// httpClient builds the client from the options. A client passed with WithHTTPClient is copied, never modified.
+func (c *rpcConfig) httpClient() *http.Client {
+	client := &http.Client{}
+	if c.client != nil {
+		copied := *c.client
+		client = &copied
+	}
+
+	transport := c.transport
+	if transport == nil {
+		transport = client.Transport
+	}
+
+	if c.proxy != nil {
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+
+		if base, ok := transport.(*http.Transport); ok {
+			withProxy := base.Clone()
+			withProxy.Proxy = http.ProxyURL(c.proxy)
+			transport = withProxy
+		}
+	}
+
+	client.Transport = transport
+	return client
+}
@@ -0,0 +1,159 @@
+package nanoproto
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// headerServer answers block_count and keeps the headers of the last request.
+func headerServer(t *testing.T) (*httptest.Server, *http.Header) {
+	t.Helper()
+
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.Write([]byte(`{"count":"1","unchecked":"0"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &header
+}
+
+func TestRPCHeaders(t *testing.T) {
+	server, header := headerServer(t)
+
+	if _, err := NewRPC(server.URL).BlockCount(); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("User-Agent"); got != DefaultUserAgent {
+		t.Errorf("User-Agent = %q, want the default", got)
+	}
+	if got := header.Get("Authorization"); got != "" {
+		t.Errorf("Authorization = %q without credentials", got)
+	}
+
+	rpc := NewRPC(server.URL,
+		WithHeader("X-Trace", "a"),
+		WithHeader("X-Trace", "b"),
+		WithBearerToken("secret"),
+		WithAPIKey("X-Api-Key", "key"),
+		WithUserAgent("test-agent"),
+	)
+	if _, err := rpc.BlockCount(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := header.Values("X-Trace"); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("X-Trace = %q, want both values", got)
+	}
+	if got := header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := header.Get("X-Api-Key"); got != "key" {
+		t.Errorf("X-Api-Key = %q", got)
+	}
+	if got := header.Get("User-Agent"); got != "test-agent" {
+		t.Errorf("User-Agent = %q", got)
+	}
+	if got := header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	// A User-Agent header works like WithUserAgent
+	if _, err := NewRPC(server.URL, WithHeader("User-Agent", "by-header")).BlockCount(); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Values("User-Agent"); len(got) != 1 || got[0] != "by-header" {
+		t.Errorf("User-Agent = %q, want only the header's", got)
+	}
+}
+
+func TestRPCProxy(t *testing.T) {
+	var requested *url.URL
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL
+		w.Write([]byte(`{"count":"1","unchecked":"0"}`))
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	transport := &http.Transport{}
+
+	for name, options := range map[string][]RPCOption{
+		"default transport": {WithProxy(proxyURL)},
+		"own transport":     {WithTransport(transport), WithProxy(proxyURL)},
+	} {
+		requested = nil
+
+		rpc := NewRPC("http://node.invalid/rpc", append(options, WithTimeout(5*time.Second))...)
+		rpc.SetRetryPolicy(NoRetry())
+
+		if _, err := rpc.BlockCount(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if requested == nil || requested.Host != "node.invalid" || requested.Path != "/rpc" {
+			t.Errorf("%s: proxy got %v, want the node URL", name, requested)
+		}
+	}
+
+	if transport.Proxy != nil {
+		t.Error("WithProxy modified the transport given with WithTransport")
+	}
+}
+
+func TestRPCTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte(`{"count":"1","unchecked":"0"}`))
+	}))
+	defer server.Close()
+
+	rpc := NewRPC(server.URL, WithTimeout(20*time.Millisecond))
+	rpc.SetRetryPolicy(NoRetry())
+
+	if _, err := rpc.BlockCount(); !errors.Is(err, errTransport) {
+		t.Errorf("got %v, want a transport error from the timeout", err)
+	}
+
+	if _, err := NewRPC(server.URL, WithTimeout(5*time.Second)).BlockCount(); err != nil {
+		t.Errorf("request within the timeout failed: %v", err)
+	}
+}
+
+type countingTransport struct {
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.requests++
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestRPCHTTPClientCopied(t *testing.T) {
+	server, _ := headerServer(t)
+
+	client := &http.Client{Timeout: time.Minute}
+	transport := &countingTransport{}
+
+	rpc := NewRPC(server.URL, WithHTTPClient(client), WithTransport(transport))
+	if rpc.client == client {
+		t.Fatal("the client given with WithHTTPClient is shared")
+	}
+	if client.Transport != nil {
+		t.Error("the client given with WithHTTPClient was modified")
+	}
+	if rpc.client.Timeout != time.Minute {
+		t.Errorf("client timeout = %v, want the given client's", rpc.client.Timeout)
+	}
+
+	if _, err := rpc.BlockCount(); err != nil {
+		t.Fatal(err)
+	}
+	if transport.requests != 1 {
+		t.Errorf("%d requests through the transport, want 1", transport.requests)
+	}
+}
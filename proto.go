@@ -0,0 +1,529 @@
+package nanoproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Protobuf wire types
+const (
+	WireVarint  = 0
+	WireFixed64 = 1
+	WireBytes   = 2
+	WireFixed32 = 5
+)
+
+var errTruncated = errors.New("proto: truncated message")
+
+func AppendVarint(b []byte, v uint64) []byte {
+	return binary.AppendUvarint(b, v)
+}
+
+func ConsumeVarint(b []byte) (uint64, int, error) {
+	v, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, 0, errTruncated
+	}
+	return v, n, nil
+}
+
+func AppendTag(b []byte, field int, wireType int) []byte {
+	return AppendVarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+func ConsumeTag(b []byte) (int, int, int, error) {
+	tag, n, err := ConsumeVarint(b)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	field := tag >> 3
+	if field == 0 || field > math.MaxInt32 {
+		return 0, 0, 0, fmt.Errorf("proto: invalid field number %d", field)
+	}
+
+	return int(field), int(tag & 7), n, nil
+}
+
+func AppendFixed32(b []byte, v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(b, v)
+}
+
+func AppendFixed64(b []byte, v uint64) []byte {
+	return binary.LittleEndian.AppendUint64(b, v)
+}
+
+func AppendBytes(b []byte, v []byte) []byte {
+	b = AppendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func ConsumeBytes(b []byte) ([]byte, int, error) {
+	length, n, err := ConsumeVarint(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	if length > uint64(len(b)-n) {
+		return nil, 0, errTruncated
+	}
+
+	return b[n : n+int(length)], n + int(length), nil
+}
+
+func EncodeZigZag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func DecodeZigZag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// ConsumeField reads the value of a field with the given wire type.
+// Varint and fixed values are returned in v, length-delimited values in data.
+func ConsumeField(b []byte, wireType int) (v uint64, data []byte, n int, err error) {
+	switch wireType {
+	case WireVarint:
+		v, n, err = ConsumeVarint(b)
+	case WireFixed64:
+		if len(b) < 8 {
+			return 0, nil, 0, errTruncated
+		}
+		v, n = binary.LittleEndian.Uint64(b), 8
+	case WireFixed32:
+		if len(b) < 4 {
+			return 0, nil, 0, errTruncated
+		}
+		v, n = uint64(binary.LittleEndian.Uint32(b)), 4
+	case WireBytes:
+		data, n, err = ConsumeBytes(b)
+	default:
+		err = fmt.Errorf("proto: unsupported wire type %d", wireType)
+	}
+
+	return v, data, n, err
+}
+
+// protoField is a struct field tagged `proto:"N,type"`, type being one of
+// varint, zigzag, fixed32, fixed64 or bytes (strings, byte slices and nested messages).
+type protoField struct {
+	index    int
+	number   int
+	encoding string
+}
+
+func (f protoField) wireType() int {
+	switch f.encoding {
+	case "fixed32":
+		return WireFixed32
+	case "fixed64":
+		return WireFixed64
+	case "bytes":
+		return WireBytes
+	}
+	return WireVarint
+}
+
+func protoFields(t reflect.Type) ([]protoField, error) {
+	var fields []protoField
+
+	for i := 0; i < t.NumField(); i++ {
+		tag, ok := t.Field(i).Tag.Lookup("proto")
+		if !ok || tag == "-" {
+			continue
+		}
+
+		number, encoding, _ := strings.Cut(tag, ",")
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 1 || n > 1<<29-1 {
+			return nil, fmt.Errorf("proto: invalid field number in tag %q of %s.%s", tag, t.Name(), t.Field(i).Name)
+		}
+
+		switch encoding {
+		case "varint", "zigzag", "fixed32", "fixed64", "bytes":
+		default:
+			return nil, fmt.Errorf("proto: invalid type in tag %q of %s.%s", tag, t.Name(), t.Field(i).Name)
+		}
+
+		if !t.Field(i).IsExported() {
+			return nil, fmt.Errorf("proto: tagged field %s.%s is not exported", t.Name(), t.Field(i).Name)
+		}
+
+		if kind := protoKind(t.Field(i).Type); !encodingAllows(encoding, kind) {
+			return nil, fmt.Errorf("proto: field %s.%s of kind %s can't be encoded as %s", t.Name(), t.Field(i).Name, kind, encoding)
+		}
+
+		fields = append(fields, protoField{i, n, encoding})
+	}
+
+	return fields, nil
+}
+
+// protoKind returns the kind of the values a field holds, looking through pointers and repeated fields.
+func protoKind(t reflect.Type) reflect.Kind {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		t = t.Elem()
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+	}
+
+	if t.Kind() == reflect.Slice {
+		return reflect.Slice // []byte
+	}
+
+	return t.Kind()
+}
+
+func encodingAllows(encoding string, kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool:
+		return encoding == "varint"
+	case reflect.Int, reflect.Int8, reflect.Int16:
+		return encoding == "varint" || encoding == "zigzag"
+	case reflect.Int32:
+		return encoding == "varint" || encoding == "zigzag" || encoding == "fixed32"
+	case reflect.Int64:
+		return encoding == "varint" || encoding == "zigzag" || encoding == "fixed64"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16:
+		return encoding == "varint"
+	case reflect.Uint32:
+		return encoding == "varint" || encoding == "fixed32"
+	case reflect.Uint64:
+		return encoding == "varint" || encoding == "fixed64"
+	case reflect.Float32:
+		return encoding == "fixed32"
+	case reflect.Float64:
+		return encoding == "fixed64"
+	case reflect.String, reflect.Slice, reflect.Struct:
+		return encoding == "bytes"
+	}
+
+	return false
+}
+
+// Marshal encodes a struct (or pointer to one) with `proto:"N,type"` tags in protobuf wire format.
+// Slices other than []byte are encoded as repeated fields, zero values are omitted.
+func Marshal(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("proto: cannot marshal %s", rv.Type())
+	}
+
+	return marshalStruct(nil, rv)
+}
+
+func marshalStruct(b []byte, rv reflect.Value) ([]byte, error) {
+	fields, err := protoFields(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range fields {
+		value := rv.Field(f.index)
+
+		if value.Kind() == reflect.Slice && value.Type().Elem().Kind() != reflect.Uint8 {
+			for i := 0; i < value.Len(); i++ {
+				if b, err = marshalValue(b, f, value.Index(i)); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		if value.IsZero() {
+			continue
+		}
+
+		if b, err = marshalValue(b, f, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+func marshalValue(b []byte, f protoField, value reflect.Value) ([]byte, error) {
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return b, nil
+		}
+		value = value.Elem()
+	}
+
+	mismatch := fmt.Errorf("proto: field %d of kind %s can't be encoded as %s", f.number, value.Kind(), f.encoding)
+
+	switch f.encoding {
+	case "varint":
+		var v uint64
+		switch value.Kind() {
+		case reflect.Bool:
+			if value.Bool() {
+				v = 1
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			v = uint64(value.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			v = value.Uint()
+		default:
+			return nil, mismatch
+		}
+		b = AppendTag(b, f.number, WireVarint)
+		return AppendVarint(b, v), nil
+
+	case "zigzag":
+		switch value.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			b = AppendTag(b, f.number, WireVarint)
+			return AppendVarint(b, EncodeZigZag(value.Int())), nil
+		}
+		return nil, mismatch
+
+	case "fixed32":
+		var v uint32
+		switch value.Kind() {
+		case reflect.Float32:
+			v = math.Float32bits(float32(value.Float()))
+		case reflect.Int32:
+			v = uint32(value.Int())
+		case reflect.Uint32:
+			v = uint32(value.Uint())
+		default:
+			return nil, mismatch
+		}
+		b = AppendTag(b, f.number, WireFixed32)
+		return AppendFixed32(b, v), nil
+
+	case "fixed64":
+		var v uint64
+		switch value.Kind() {
+		case reflect.Float64:
+			v = math.Float64bits(value.Float())
+		case reflect.Int64:
+			v = uint64(value.Int())
+		case reflect.Uint64:
+			v = value.Uint()
+		default:
+			return nil, mismatch
+		}
+		b = AppendTag(b, f.number, WireFixed64)
+		return AppendFixed64(b, v), nil
+
+	case "bytes":
+		var data []byte
+		switch {
+		case value.Kind() == reflect.String:
+			data = []byte(value.String())
+		case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8:
+			data = value.Bytes()
+		case value.Kind() == reflect.Struct:
+			var err error
+			if data, err = marshalStruct(nil, value); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, mismatch
+		}
+		b = AppendTag(b, f.number, WireBytes)
+		return AppendBytes(b, data), nil
+	}
+
+	return nil, mismatch
+}
+
+// Unmarshal decodes protobuf wire format into a struct pointer with `proto:"N,type"` tags.
+// Unknown fields are skipped and packed repeated scalars are accepted.
+func Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("proto: Unmarshal needs a non-nil struct pointer")
+	}
+
+	return unmarshalStruct(data, rv.Elem())
+}
+
+func unmarshalStruct(data []byte, rv reflect.Value) error {
+	fields, err := protoFields(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	byNumber := make(map[int]protoField, len(fields))
+	for _, f := range fields {
+		byNumber[f.number] = f
+	}
+
+	for len(data) > 0 {
+		number, wireType, n, err := ConsumeTag(data)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+
+		v, bytesValue, n, err := ConsumeField(data, wireType)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+
+		f, ok := byNumber[number]
+		if !ok {
+			continue
+		}
+
+		if err := unmarshalField(rv.Field(f.index), f, wireType, v, bytesValue); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func unmarshalField(field reflect.Value, f protoField, wireType int, v uint64, data []byte) error {
+	if field.Kind() != reflect.Slice || field.Type().Elem().Kind() == reflect.Uint8 {
+		return setValue(field, f, wireType, v, data)
+	}
+
+	// Packed repeated scalars arrive as one length-delimited field
+	if wireType == WireBytes && f.encoding != "bytes" {
+		for len(data) > 0 {
+			v, _, n, err := ConsumeField(data, f.wireType())
+			if err != nil {
+				return err
+			}
+			data = data[n:]
+
+			if err := appendValue(field, f, f.wireType(), v, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return appendValue(field, f, wireType, v, data)
+}
+
+func appendValue(slice reflect.Value, f protoField, wireType int, v uint64, data []byte) error {
+	elem := reflect.New(slice.Type().Elem()).Elem()
+	if err := setValue(elem, f, wireType, v, data); err != nil {
+		return err
+	}
+
+	slice.Set(reflect.Append(slice, elem))
+	return nil
+}
+
+func setValue(value reflect.Value, f protoField, wireType int, v uint64, data []byte) error {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		value = value.Elem()
+	}
+
+	if wireType != f.wireType() {
+		return fmt.Errorf("proto: field %d has wire type %d, expected %d", f.number, wireType, f.wireType())
+	}
+
+	switch f.encoding {
+	case "zigzag":
+		value.SetInt(DecodeZigZag(v))
+		return nil
+	case "fixed32":
+		if value.Kind() == reflect.Float32 {
+			value.SetFloat(float64(math.Float32frombits(uint32(v))))
+			return nil
+		}
+	case "fixed64":
+		if value.Kind() == reflect.Float64 {
+			value.SetFloat(math.Float64frombits(v))
+			return nil
+		}
+	case "bytes":
+		switch {
+		case value.Kind() == reflect.String:
+			value.SetString(string(data))
+		case value.Kind() == reflect.Slice:
+			value.SetBytes(append([]byte(nil), data...))
+		case value.Kind() == reflect.Struct:
+			return unmarshalStruct(data, value)
+		default:
+			return fmt.Errorf("proto: field %d of kind %s can't hold bytes", f.number, value.Kind())
+		}
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.Bool:
+		value.SetBool(v != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f.encoding == "fixed32" {
+			value.SetInt(int64(int32(v)))
+		} else {
+			value.SetInt(int64(v))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value.SetUint(v)
+	default:
+		return fmt.Errorf("proto: field %d of kind %s can't hold %s", f.number, value.Kind(), f.encoding)
+	}
+
+	return nil
+}
+
+// PutMessage encodes v with Marshal and stores it.
+func (s *NanoDataStorage) PutMessage(v any) error {
+	data, err := Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return s.PutData(data)
+}
+
+// GetMessages decodes every message stored on address into a T, which must be a struct with proto tags.
+// Messages that don't decode are reported in the error, joined, next to the values that did.
+func GetMessages[T any](s *NanoDataStorage, address *string) ([]T, error) {
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("proto: cannot decode messages into %s", t)
+	}
+	if _, err := protoFields(t); err != nil {
+		return nil, err
+	}
+
+	messages, err := s.GetData(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []T
+	var errs []error
+
+	for i, message := range messages {
+		var value T
+		if err := Unmarshal(message, &value); err != nil {
+			errs = append(errs, fmt.Errorf("message %d: %v", i, err))
+			continue
+		}
+
+		values = append(values, value)
+	}
+
+	return values, errors.Join(errs...)
+}
@@ -0,0 +1,233 @@
+package nanoproto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Message layouts from the protobuf encoding guide, the expected bytes are what protoc produces.
+type protoTest1 struct {
+	A int32 `proto:"1,varint"`
+}
+
+type protoTest2 struct {
+	B string `proto:"2,bytes"`
+}
+
+type protoTest3 struct {
+	C protoTest1 `proto:"3,bytes"`
+}
+
+type protoScalars struct {
+	Sint   int64   `proto:"1,zigzag"`
+	Fixed  uint32  `proto:"5,fixed32"`
+	Double float64 `proto:"6,fixed64"`
+	Neg    int32   `proto:"2,varint"`
+	Flag   bool    `proto:"7,varint"`
+}
+
+type protoRepeated struct {
+	Values []int32      `proto:"4,varint"`
+	Items  []protoTest1 `proto:"8,bytes"`
+	Blobs  [][]byte     `proto:"9,bytes"`
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestMarshalWireBytes(t *testing.T) {
+	cases := []struct {
+		name  string
+		value any
+		wire  string
+	}{
+		{"varint", &protoTest1{150}, "08 96 01"},
+		{"string", &protoTest2{"testing"}, "12 07 74 65 73 74 69 6e 67"},
+		{"nested", &protoTest3{protoTest1{150}}, "1a 03 08 96 01"},
+		{"zigzag -1", &protoScalars{Sint: -1}, "08 01"},
+		{"zigzag 1", &protoScalars{Sint: 1}, "08 02"},
+		{"zigzag -150", &protoScalars{Sint: -150}, "08 ab 02"},
+		{"negative int32", &protoScalars{Neg: -1}, "10 ff ff ff ff ff ff ff ff ff 01"},
+		{"fixed32", &protoScalars{Fixed: 1}, "2d 01 00 00 00"},
+		{"double", &protoScalars{Double: 1}, "31 00 00 00 00 00 00 f0 3f"},
+		{"bool", &protoScalars{Flag: true}, "38 01"},
+		{"repeated", &protoRepeated{Values: []int32{1, 2, 3}}, "20 01 20 02 20 03"},
+		{"repeated messages", &protoRepeated{Items: []protoTest1{{1}, {150}}}, "42 02 08 01 42 03 08 96 01"},
+		{"repeated bytes", &protoRepeated{Blobs: [][]byte{{0xaa}, {0xbb, 0xcc}}}, "4a 01 aa 4a 02 bb cc"},
+		{"zero values omitted", &protoScalars{}, ""},
+	}
+
+	for _, c := range cases {
+		got, err := Marshal(c.value)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+
+		want := mustHex(t, c.wire)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: Marshal = % x, want % x", c.name, got, want)
+			continue
+		}
+
+		// And back
+		decoded := reflect.New(reflect.TypeOf(c.value).Elem())
+		if err := Unmarshal(want, decoded.Interface()); err != nil {
+			t.Errorf("%s: Unmarshal: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(decoded.Interface(), c.value) && len(want) > 0 {
+			t.Errorf("%s: Unmarshal = %+v, want %+v", c.name, decoded.Elem(), reflect.ValueOf(c.value).Elem())
+		}
+	}
+}
+
+func TestUnmarshalPacked(t *testing.T) {
+	// proto3 packs repeated scalars: field 4, length 3
+	var m protoRepeated
+	if err := Unmarshal(mustHex(t, "22 03 01 02 03"), &m); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.Values, []int32{1, 2, 3}) {
+		t.Fatalf("Values = %v, want [1 2 3]", m.Values)
+	}
+}
+
+func TestUnmarshalSkipsUnknownFields(t *testing.T) {
+	wire := mustHex(t, strings.Join([]string{
+		"10 05",                      // field 2, varint
+		"19 01 02 03 04 05 06 07 08", // field 3, fixed64
+		"22 02 aa bb",                // field 4, bytes
+		"2d 01 02 03 04",             // field 5, fixed32
+		"08 96 01",                   // field 1, known
+	}, " "))
+
+	var m protoTest1
+	if err := Unmarshal(wire, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.A != 150 {
+		t.Fatalf("A = %d, want 150", m.A)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var m protoTest1
+	for _, wire := range []string{"08", "08 96", "12 05 61", "0f 00"} {
+		if err := Unmarshal(mustHex(t, wire), &m); err == nil {
+			t.Errorf("Unmarshal(% s) accepted a malformed message", wire)
+		}
+	}
+
+	// A string where a varint is expected
+	if err := Unmarshal(mustHex(t, "0a 01 61"), &m); err == nil {
+		t.Error("Unmarshal accepted the wrong wire type")
+	}
+}
+
+func TestInvalidTags(t *testing.T) {
+	cases := map[string]any{
+		"zigzag on uint": &struct {
+			V uint64 `proto:"1,zigzag"`
+		}{},
+		"zigzag on string": &struct {
+			V string `proto:"1,zigzag"`
+		}{},
+		"varint on string": &struct {
+			V string `proto:"1,varint"`
+		}{},
+		"fixed32 on int64": &struct {
+			V int64 `proto:"1,fixed32"`
+		}{},
+		"bytes on int": &struct {
+			V int `proto:"1,bytes"`
+		}{},
+		"unexported field": &struct {
+			v int `proto:"1,varint"`
+		}{},
+		"unknown type": &struct {
+			V int `proto:"1,sint"`
+		}{},
+		"invalid number": &struct {
+			V int `proto:"0,varint"`
+		}{},
+		"repeated mismatch": &struct {
+			V []string `proto:"1,varint"`
+		}{},
+	}
+
+	for name, v := range cases {
+		if _, err := Marshal(v); err == nil {
+			t.Errorf("%s: Marshal accepted the tag", name)
+		}
+
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("%s: Unmarshal panicked: %v", name, r)
+				}
+			}()
+
+			if err := Unmarshal(mustHex(t, "08 01"), v); err == nil {
+				t.Errorf("%s: Unmarshal accepted the tag", name)
+			}
+		}()
+	}
+}
+
+func TestGetMessages(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+	storage := NewNanoDataStorage(rpc, &address, &privateKey)
+
+	if err := storage.PutMessage(protoTest1{A: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.PutData([]byte{0x08}); err != nil { // Field 1 without its varint
+		t.Fatal(err)
+	}
+	if err := storage.PutMessage(&protoTest1{A: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := GetMessages[protoTest1](storage, &address)
+	if err == nil || !strings.Contains(err.Error(), "message 1") {
+		t.Errorf("got %v, want the decoding error of message 1", err)
+	}
+	if len(values) != 2 || values[0].A != 1 || values[1].A != 2 {
+		t.Errorf("decoded %+v", values)
+	}
+}
+
+func TestGetMessagesInvalidType(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, _ := node.testAccount(t, 0)
+	storage := NewNanoDataStorage(rpc, nil, nil)
+
+	type badTag struct {
+		A int32 `proto:"0,varint"`
+	}
+
+	if _, err := GetMessages[string](storage, &address); err == nil {
+		t.Error("read messages into a string")
+	}
+	if _, err := GetMessages[*protoTest1](storage, &address); err == nil {
+		t.Error("read messages into a pointer")
+	}
+	if _, err := GetMessages[badTag](storage, &address); err == nil {
+		t.Error("read messages into a struct with an invalid tag")
+	}
+
+	if node.calls["account_history"] != 0 {
+		t.Error("read the history before checking the type")
+	}
+}
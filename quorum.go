@@ -0,0 +1,166 @@
+package nanoproto
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// NodeState is what one node reported about an account during a quorum read.
+type NodeState struct {
+	Node               string
+	Frontier           string
+	ConfirmationHeight uint64
+	ConfirmedFrontier  string
+	Err                error // Set when the node couldn't be queried
+}
+
+// DivergenceError is returned by quorum reads when no block of the account is confirmed by enough nodes.
+type DivergenceError struct {
+	Account  string
+	Required int
+	Nodes    []NodeState
+}
+
+func (e *DivergenceError) Error() string {
+	var states []string
+	for _, node := range e.Nodes {
+		if node.Err != nil {
+			states = append(states, fmt.Sprintf("%s: %v", node.Node, node.Err))
+			continue
+		}
+
+		states = append(states, fmt.Sprintf("%s: confirmed %s at height %d", node.Node, node.ConfirmedFrontier, node.ConfirmationHeight))
+	}
+
+	return fmt.Sprintf("no block of %s is confirmed by %d nodes (%s)", e.Account, e.Required, strings.Join(states, "; "))
+}
+
+type quorum struct {
+	nodes    []*RPC
+	required int
+}
+
+// SetQuorum makes reads return data only up to the highest block confirmed by at least required of nodes.
+// This covers GetData, GetDataRefs and the incremental GetDataSince/ReadSince (Cursor) and KV reads.
+// The history itself is still fetched through the storage RPC client. nil nodes disables quorum reads.
+func (s *NanoDataStorage) SetQuorum(nodes []*RPC, required int) error {
+	if nodes == nil {
+		s.quorum = nil
+		return nil
+	}
+
+	if required < 1 || required > len(nodes) {
+		return fmt.Errorf("quorum must be between 1 and %d nodes", len(nodes))
+	}
+
+	s.quorum = &quorum{nodes, required}
+	return nil
+}
+
+// confirmed returns the hash of the highest block of address confirmed by the required number of nodes.
+func (q *quorum) confirmed(address string) (string, error) {
+	states := q.states(address)
+
+	type candidate struct {
+		hash   string
+		height uint64
+	}
+
+	var candidates []candidate
+	for _, state := range states {
+		if state.Err == nil && state.ConfirmationHeight > 0 {
+			candidates = append(candidates, candidate{state.ConfirmedFrontier, state.ConfirmationHeight})
+		}
+	}
+
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		if a.height != b.height {
+			return cmp.Compare(b.height, a.height)
+		}
+		return strings.Compare(a.hash, b.hash)
+	})
+	candidates = slices.Compact(candidates)
+
+	for _, c := range candidates {
+		agreeing := 0
+
+		for i, state := range states {
+			if state.Err != nil || state.ConfirmationHeight < c.height {
+				continue
+			}
+
+			if strings.EqualFold(state.ConfirmedFrontier, c.hash) || q.nodes[i].confirms(address, c.hash, c.height) {
+				agreeing++
+			}
+		}
+
+		if agreeing >= q.required {
+			return c.hash, nil
+		}
+	}
+
+	return "", &DivergenceError{address, q.required, states}
+}
+
+// states queries every node for the frontier and confirmation height of address.
+func (q *quorum) states(address string) []NodeState {
+	var wg sync.WaitGroup
+	states := make([]NodeState, len(q.nodes))
+
+	for i, node := range q.nodes {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			states[i].Node = node.url
+
+			info, err := node.AccountInfo(address)
+			if err != nil {
+				states[i].Err = err
+				return
+			}
+
+			height, err := strconv.ParseUint(info.ConfirmationHeight, 10, 64)
+			if err != nil {
+				states[i].Err = fmt.Errorf("invalid confirmation height %q", info.ConfirmationHeight)
+				return
+			}
+
+			states[i].Frontier = info.Frontier
+			states[i].ConfirmationHeight = height
+			states[i].ConfirmedFrontier = info.ConfirmationHeightFrontier
+		}()
+	}
+
+	wg.Wait()
+	return states
+}
+
+// confirms reports whether the node has hash confirmed as the block of account at height.
+func (r *RPC) confirms(account, hash string, height uint64) bool {
+	info, err := r.BlockInfo(hash)
+	return err == nil && info.Confirmed && info.Height == height && sameAccount(info.BlockAccount, account)
+}
+
+// sameAccount compares addresses regardless of their xrb_/nano_ prefix.
+func sameAccount(a, b string) bool {
+	keyA, errA := nanoAddressToPublicKey(a)
+	keyB, errB := nanoAddressToPublicKey(b)
+	return errA == nil && errB == nil && keyA == keyB
+}
+
+// truncateHistory cuts history after the block with the given hash.
+func truncateHistory(history []AccountHistoryRepChange, hash string) ([]AccountHistoryRepChange, bool) {
+	for i, item := range history {
+		if strings.EqualFold(item.Hash, hash) {
+			return history[:i+1], true
+		}
+	}
+
+	return nil, false
+}
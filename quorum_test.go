@@ -0,0 +1,193 @@
+package nanoproto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// quorumNodes starts count nodes holding the same account and a storage writing to the first one.
+func quorumNodes(t *testing.T, count int) ([]*testNode, []*RPC, *NanoDataStorage, string) {
+	t.Helper()
+
+	var nodes []*testNode
+	var clients []*RPC
+	for i := 0; i < count; i++ {
+		node, rpc := newTestNode(t)
+		nodes = append(nodes, node)
+		clients = append(clients, rpc)
+	}
+
+	address, privateKey := nodes[0].testAccount(t, 0)
+	for _, node := range nodes[1:] {
+		node.testAccount(t, 0)
+	}
+
+	return nodes, clients, NewNanoDataStorage(clients[0], &address, &privateKey), address
+}
+
+// syncNodes copies the chain of address from the first node to the others.
+func syncNodes(nodes []*testNode, address string) {
+	chain := nodes[0].chain(address)
+	for _, node := range nodes[1:] {
+		node.mu.Lock()
+		node.chains[address] = append([]AccountHistoryRepChange(nil), chain...)
+		node.mu.Unlock()
+	}
+}
+
+func TestQuorumAgreeing(t *testing.T) {
+	nodes, clients, storage, address := quorumNodes(t, 3)
+
+	if err := storage.PutData(testPayload); err != nil {
+		t.Fatal(err)
+	}
+	syncNodes(nodes, address)
+
+	if err := storage.SetQuorum(clients, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := storage.GetData(&address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || !bytes.Equal(data[0], testPayload) {
+		t.Fatalf("read %q", data)
+	}
+}
+
+func TestQuorumDivergence(t *testing.T) {
+	nodes, clients, storage, address := quorumNodes(t, 3)
+
+	if err := storage.PutData(testPayload); err != nil {
+		t.Fatal(err)
+	}
+	syncNodes(nodes, address)
+
+	// The second node cemented a different block at the same height and the third can't be reached
+	nodes[1].mu.Lock()
+	chain := nodes[1].chains[address]
+	nodes[1].chains[address] = chain[:len(chain)-1]
+	nodes[1].compete(address, "F00DF00DF00DF00DF00DF00DF00DF00DF00DF00DF00DF00DF00DF00DF00DF00D")
+	nodes[1].mu.Unlock()
+	nodes[2].hook = func(action string, request map[string]interface{}) (interface{}, bool) {
+		return map[string]string{"error": "Unreachable"}, true
+	}
+
+	if err := storage.SetQuorum(clients, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := storage.GetData(&address)
+
+	var divergence *DivergenceError
+	if !errors.As(err, &divergence) {
+		t.Fatalf("got %v, want a DivergenceError", err)
+	}
+	if divergence.Required != 2 || len(divergence.Nodes) != 3 || divergence.Nodes[2].Err == nil {
+		t.Errorf("got %+v", divergence)
+	}
+	if divergence.Nodes[0].ConfirmedFrontier == divergence.Nodes[1].ConfirmedFrontier {
+		t.Error("nodes report the same confirmed frontier")
+	}
+}
+
+func TestQuorumTruncatesAtConfirmedHeight(t *testing.T) {
+	nodes, clients, storage, address := quorumNodes(t, 2)
+
+	if err := storage.PutData([]byte("confirmed")); err != nil {
+		t.Fatal(err)
+	}
+	confirmed := len(nodes[0].chain(address))
+
+	if err := storage.PutData([]byte("unconfirmed")); err != nil {
+		t.Fatal(err)
+	}
+	syncNodes(nodes, address)
+
+	for _, node := range nodes {
+		node.mu.Lock()
+		node.cemented[address] = confirmed
+		node.mu.Unlock()
+	}
+
+	if err := storage.SetQuorum(clients, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := storage.GetData(&address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || string(data[0]) != "confirmed" {
+		t.Fatalf("read %q, want only the confirmed message", data)
+	}
+
+	// Without quorum both messages are read
+	storage.SetQuorum(nil, 0)
+	if data, err := storage.GetData(&address); err != nil || len(data) != 2 {
+		t.Fatalf("read %q (%v) without quorum", data, err)
+	}
+}
+
+// A node that confirmed further still agrees on a lower block, which is checked with block_info.
+func TestQuorumBlockInfoFallback(t *testing.T) {
+	nodes, clients, storage, address := quorumNodes(t, 2)
+
+	if err := storage.PutData([]byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	confirmed := len(nodes[0].chain(address))
+
+	if err := storage.PutData([]byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	syncNodes(nodes, address)
+
+	nodes[1].mu.Lock()
+	nodes[1].cemented[address] = confirmed
+	nodes[1].mu.Unlock()
+
+	if err := storage.SetQuorum(clients, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := storage.GetData(&address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || string(data[0]) != "first" {
+		t.Fatalf("read %q, want the message both nodes confirmed", data)
+	}
+	if nodes[0].calls["block_info"] == 0 {
+		t.Error("the node that confirmed further wasn't asked about the lower block")
+	}
+
+	// A quorum of one takes the highest confirmation
+	storage.SetQuorum(clients, 1)
+	if data, err := storage.GetData(&address); err != nil || len(data) != 2 {
+		t.Fatalf("read %q (%v) with a quorum of one", data, err)
+	}
+}
+
+func TestSetQuorumBounds(t *testing.T) {
+	_, clients, storage, _ := quorumNodes(t, 2)
+
+	for _, required := range []int{0, 3} {
+		if err := storage.SetQuorum(clients, required); err == nil {
+			t.Errorf("accepted a quorum of %d out of 2", required)
+		}
+	}
+}
+
+func TestTruncateHistory(t *testing.T) {
+	history := []AccountHistoryRepChange{{Hash: "AA"}, {Hash: "BB"}, {Hash: "CC"}}
+
+	if got, ok := truncateHistory(history, "bb"); !ok || len(got) != 2 {
+		t.Errorf("got %v, %v", got, ok)
+	}
+	if _, ok := truncateHistory(history, "DD"); ok {
+		t.Error("truncated at a missing block")
+	}
+}
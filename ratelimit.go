@@ -0,0 +1,131 @@
+package nanoproto
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter spaces out RPC calls with token buckets: one per action given a limit with SetLimit,
+// and a shared one for every other action. Share a limiter between the RPC clients of every
+// NanoDataStorage talking to the same node so they stay within its limits together.
+type RateLimiter struct {
+	mu          sync.Mutex
+	defaultRate bucketRate
+	rates       map[string]bucketRate
+	buckets     map[string]*bucket
+	pausedUntil time.Time // Set from Retry-After, holds back every action
+}
+
+type bucketRate struct {
+	perSecond float64
+	burst     int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter allows perSecond calls per second on average with bursts of up to burst calls.
+// A rate of 0 means unlimited.
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	return &RateLimiter{defaultRate: bucketRate{perSecond, max(burst, 1)}, rates: map[string]bucketRate{}, buckets: map[string]*bucket{}}
+}
+
+// SetLimit gives action its own bucket, e.g. a tighter one for work_generate or process.
+func (l *RateLimiter) SetLimit(action string, perSecond float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rates[action] = bucketRate{perSecond, max(burst, 1)}
+	delete(l.buckets, action)
+}
+
+// Pause holds back every call for d, as asked by a Retry-After header.
+func (l *RateLimiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// Wait blocks until a call of action is allowed.
+func (l *RateLimiter) Wait(action string) {
+	for {
+		delay := l.reserve(action)
+		if delay <= 0 {
+			return
+		}
+
+		time.Sleep(delay)
+	}
+}
+
+// reserve takes a token for action, or returns how long to wait before trying again.
+func (l *RateLimiter) reserve(action string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+
+	key, rate := "", l.defaultRate
+	if actionRate, ok := l.rates[action]; ok {
+		key, rate = action, actionRate
+	}
+
+	if rate.perSecond <= 0 {
+		return 0
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{float64(rate.burst), now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = min(float64(rate.burst), b.tokens+now.Sub(b.last).Seconds()*rate.perSecond)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) / rate.perSecond * float64(time.Second))
+}
+
+// SetRateLimiter makes every call wait for the limiter, nil disables rate limiting.
+func (r *RPC) SetRateLimiter(limiter *RateLimiter) {
+	r.limiter = limiter
+}
+
+// WithRateLimiter sets the rate limiter of a new client, see SetRateLimiter.
+func WithRateLimiter(limiter *RateLimiter) RPCOption {
+	return func(c *rpcConfig) {
+		c.limiter = limiter
+	}
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		return max(time.Until(date), 0)
+	}
+
+	return 0
+}
@@ -0,0 +1,114 @@
+package nanoproto
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	limiter := NewRateLimiter(10, 2)
+	limiter.SetLimit("process", 1, 1)
+
+	// The burst is available right away, then a token comes every 100ms
+	for i := 0; i < 2; i++ {
+		if delay := limiter.reserve("account_info"); delay != 0 {
+			t.Fatalf("call %d within the burst waits %v", i+1, delay)
+		}
+	}
+	if delay := limiter.reserve("account_info"); delay <= 0 || delay > 100*time.Millisecond {
+		t.Errorf("call past the burst waits %v, want up to 100ms", delay)
+	}
+
+	// Actions given a limit don't share the default bucket
+	if delay := limiter.reserve("process"); delay != 0 {
+		t.Errorf("first process call waits %v", delay)
+	}
+	if delay := limiter.reserve("process"); delay <= 900*time.Millisecond || delay > time.Second {
+		t.Errorf("second process call waits %v, want about 1s", delay)
+	}
+
+	// Waiting out the delay makes the token available
+	delay := limiter.reserve("account_info")
+	time.Sleep(delay)
+	if delay := limiter.reserve("account_info"); delay != 0 {
+		t.Errorf("call after waiting still waits %v", delay)
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	limiter := NewRateLimiter(0, 0)
+	for i := 0; i < 100; i++ {
+		if delay := limiter.reserve("process"); delay != 0 {
+			t.Fatalf("unlimited call waits %v", delay)
+		}
+	}
+}
+
+func TestRateLimiterPause(t *testing.T) {
+	limiter := NewRateLimiter(0, 0)
+	limiter.SetLimit("process", 100, 100)
+
+	limiter.Pause(time.Second)
+	limiter.Pause(10 * time.Millisecond) // A shorter pause doesn't cut the longer one
+
+	for _, action := range []string{"account_info", "process"} {
+		if delay := limiter.reserve(action); delay <= 900*time.Millisecond || delay > time.Second {
+			t.Errorf("%s waits %v during the pause, want about 1s", action, delay)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for _, test := range []struct {
+		header   string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"3", 3 * time.Second, 3 * time.Second},
+		{"0", 0, 0},
+		{"-5", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0},
+	} {
+		if got := retryAfter(test.header); got < test.min || got > test.max {
+			t.Errorf("retryAfter(%q) = %v, want between %v and %v", test.header, got, test.min, test.max)
+		}
+	}
+}
+
+// A rate limited retry waits once for the longer of its backoff and Retry-After, not for both.
+func TestCallRetryAfterWaitsOnce(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"count":"1","unchecked":"0"}`))
+	}))
+	defer server.Close()
+
+	for _, limiter := range []*RateLimiter{nil, NewRateLimiter(0, 0)} {
+		calls.Store(0)
+
+		rpc := NewRPC(server.URL, WithRateLimiter(limiter))
+		rpc.SetRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: 400 * time.Millisecond, MaxDelay: 400 * time.Millisecond, RetryStatusCodes: []int{429}})
+
+		start := time.Now()
+		if _, err := rpc.BlockCount(); err != nil {
+			t.Fatal(err)
+		}
+
+		if elapsed := time.Since(start); elapsed < time.Second || elapsed > 1300*time.Millisecond {
+			t.Errorf("limiter %v: retry took %v, want about the 1s asked by the node", limiter != nil, elapsed)
+		}
+		if calls.Load() != 2 {
+			t.Errorf("limiter %v: %d calls, want 2", limiter != nil, calls.Load())
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package nanoproto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Unit is a Nano denomination, expressed as the power of ten of raw it is worth.
+type Unit uint8
+
+const (
+	UnitRaw       Unit = 0
+	UnitMicroNano Unit = 24
+	UnitMilliNano Unit = 27
+	UnitNano      Unit = 30 // 1 Nano (XNO) = 10^30 raw
+	UnitXNO            = UnitNano
+)
+
+var maxRaw = new(big.Int).Lsh(big.NewInt(1), 128) // Balances are 128-bit unsigned integers
+
+// Raw is an amount of raw, the smallest Nano unit. The zero value is 0 raw.
+// Values are immutable and always between 0 and 2^128-1.
+type Raw struct {
+	value *big.Int
+}
+
+// NewRaw returns n raw.
+func NewRaw(n uint64) Raw {
+	return Raw{new(big.Int).SetUint64(n)}
+}
+
+// RawFromBigInt checks that n fits in 128 bits and returns it as Raw.
+func RawFromBigInt(n *big.Int) (Raw, error) {
+	if n.Sign() < 0 || n.Cmp(maxRaw) >= 0 {
+		return Raw{}, fmt.Errorf("amount %s is out of the 128-bit range", n)
+	}
+
+	return Raw{new(big.Int).Set(n)}, nil
+}
+
+// RawFromBytes decodes a 16-byte big-endian balance.
+func RawFromBytes(b []byte) (Raw, error) {
+	if len(b) != 16 {
+		return Raw{}, fmt.Errorf("balance must be 16 bytes, got %d", len(b))
+	}
+
+	return Raw{new(big.Int).SetBytes(b)}, nil
+}
+
+// ParseRaw parses an integer amount of raw, as used by the node RPC.
+func ParseRaw(s string) (Raw, error) {
+	return ParseAmount(s, UnitRaw)
+}
+
+// ParseNano parses a decimal amount of Nano (XNO), e.g. "1.5".
+func ParseNano(s string) (Raw, error) {
+	return ParseAmount(s, UnitNano)
+}
+
+// ParseAmount parses a decimal amount in unit. The amount must be a whole number of raw.
+func ParseAmount(s string, unit Unit) (Raw, error) {
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" || len(fraction) > int(unit) {
+		return Raw{}, fmt.Errorf("invalid amount: %q", s)
+	}
+
+	digits := whole + fraction + strings.Repeat("0", int(unit)-len(fraction))
+	if strings.ContainsFunc(digits, func(c rune) bool { return c < '0' || c > '9' }) {
+		return Raw{}, fmt.Errorf("invalid amount: %q", s)
+	}
+
+	n, _ := new(big.Int).SetString(digits, 10)
+	return RawFromBigInt(n)
+}
+
+func (r Raw) int() *big.Int {
+	if r.value == nil {
+		return new(big.Int)
+	}
+	return r.value
+}
+
+// BigInt returns a copy of the amount.
+func (r Raw) BigInt() *big.Int {
+	return new(big.Int).Set(r.int())
+}
+
+// String returns the amount in raw.
+func (r Raw) String() string {
+	return r.int().String()
+}
+
+// Format returns the amount in unit as an exact decimal without trailing zeros, e.g. "1.5" for 1.5 Nano.
+func (r Raw) Format(unit Unit) string {
+	digits := r.String()
+	if unit == UnitRaw {
+		return digits
+	}
+
+	if len(digits) <= int(unit) {
+		digits = strings.Repeat("0", int(unit)-len(digits)+1) + digits
+	}
+
+	whole, fraction := digits[:len(digits)-int(unit)], strings.TrimRight(digits[len(digits)-int(unit):], "0")
+	if fraction == "" {
+		return whole
+	}
+
+	return whole + "." + fraction
+}
+
+// Bytes returns the 16-byte big-endian encoding used in block hashes.
+func (r Raw) Bytes() []byte {
+	return r.int().FillBytes(make([]byte, 16))
+}
+
+func (r Raw) Add(o Raw) (Raw, error) {
+	return RawFromBigInt(new(big.Int).Add(r.int(), o.int()))
+}
+
+// Sub returns r - o, failing when o is larger than r.
+func (r Raw) Sub(o Raw) (Raw, error) {
+	if r.Cmp(o) < 0 {
+		return Raw{}, errors.New("insufficient balance")
+	}
+
+	return Raw{new(big.Int).Sub(r.int(), o.int())}, nil
+}
+
+func (r Raw) Mul(n uint64) (Raw, error) {
+	return RawFromBigInt(new(big.Int).Mul(r.int(), new(big.Int).SetUint64(n)))
+}
+
+// Cmp returns -1, 0 or 1 when r is less than, equal to or greater than o.
+func (r Raw) Cmp(o Raw) int {
+	return r.int().Cmp(o.int())
+}
+
+func (r Raw) IsZero() bool {
+	return r.int().Sign() == 0
+}
+
+// MarshalJSON encodes the amount as a string of raw, like the node does.
+func (r Raw) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON accepts a quoted string of raw, like the node sends. Bare numbers are rejected
+// because they lose precision in most JSON encoders.
+func (r *Raw) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("amount must be a string of raw: %v", err)
+	}
+
+	parsed, err := ParseRaw(s)
+	if err != nil {
+		return err
+	}
+
+	*r = parsed
+	return nil
+}
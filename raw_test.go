@@ -0,0 +1,160 @@
+package nanoproto
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+const maxRawString = "340282366920938463463374607431768211455" // 2^128-1
+
+func TestParseAmount(t *testing.T) {
+	cases := []struct {
+		amount string
+		unit   Unit
+		raw    string // Empty when the amount must be rejected
+	}{
+		{"0", UnitRaw, "0"},
+		{"1000", UnitRaw, "1000"},
+		{"1", UnitNano, "1" + strings.Repeat("0", 30)},
+		{"1.5", UnitNano, "15" + strings.Repeat("0", 29)},
+		{"0.000000000000000000000000000001", UnitNano, "1"},
+		{"1", UnitMilliNano, "1" + strings.Repeat("0", 27)},
+		{"0.001", UnitMilliNano, "1" + strings.Repeat("0", 24)},
+		{"2.5", UnitMicroNano, "25" + strings.Repeat("0", 23)},
+		{".5", UnitNano, "5" + strings.Repeat("0", 29)},
+		{maxRawString, UnitRaw, maxRawString},
+		{"340282366.920938463463374607431768211455", UnitNano, maxRawString},
+
+		// Fractional digits beyond the unit's precision
+		{"0.0000000000000000000000000000001", UnitNano, ""},
+		{"1.0000000000000000000000000000000", UnitNano, ""},
+		{"0.1", UnitRaw, ""},
+		{"1.", UnitRaw, "1"},
+
+		// Negative, malformed and out of range
+		{"-1", UnitRaw, ""},
+		{"-0.5", UnitNano, ""},
+		{"+1", UnitRaw, ""},
+		{"1e3", UnitRaw, ""},
+		{"", UnitRaw, ""},
+		{".", UnitNano, ""},
+		{"1.2.3", UnitNano, ""},
+		{"340282366920938463463374607431768211456", UnitRaw, ""},
+		{"340282366.920938463463374607431768211456", UnitNano, ""},
+		{"1000000000", UnitNano, ""},
+	}
+
+	for _, c := range cases {
+		r, err := ParseAmount(c.amount, c.unit)
+		if c.raw == "" {
+			if err == nil {
+				t.Errorf("ParseAmount(%q, %d) = %s, want an error", c.amount, c.unit, r)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseAmount(%q, %d): %v", c.amount, c.unit, err)
+		} else if r.String() != c.raw {
+			t.Errorf("ParseAmount(%q, %d) = %s, want %s", c.amount, c.unit, r, c.raw)
+		}
+	}
+}
+
+func TestRawFormat(t *testing.T) {
+	cases := []struct {
+		raw  string
+		unit Unit
+		want string
+	}{
+		{"0", UnitNano, "0"},
+		{"1", UnitNano, "0.000000000000000000000000000001"},
+		{"1" + strings.Repeat("0", 30), UnitNano, "1"},
+		{"15" + strings.Repeat("0", 29), UnitNano, "1.5"},
+		{"1" + strings.Repeat("0", 24), UnitMicroNano, "1"},
+		{"1" + strings.Repeat("0", 24), UnitMilliNano, "0.001"},
+		{maxRawString, UnitNano, "340282366.920938463463374607431768211455"},
+		{maxRawString, UnitRaw, maxRawString},
+	}
+
+	for _, c := range cases {
+		r, err := ParseRaw(c.raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Format(c.unit); got != c.want {
+			t.Errorf("%s.Format(%d) = %s, want %s", c.raw, c.unit, got, c.want)
+		}
+
+		// Formatting must round-trip
+		if back, err := ParseAmount(r.Format(c.unit), c.unit); err != nil || back.Cmp(r) != 0 {
+			t.Errorf("ParseAmount(%s.Format(%d)) = %s, %v", c.raw, c.unit, back, err)
+		}
+	}
+}
+
+func TestRawArithmetic(t *testing.T) {
+	max, _ := ParseRaw(maxRawString)
+
+	if _, err := max.Add(NewRaw(1)); err == nil {
+		t.Error("Add overflowed 128 bits without an error")
+	}
+	if _, err := NewRaw(1).Sub(NewRaw(2)); err == nil {
+		t.Error("Sub went negative without an error")
+	}
+	if _, err := max.Mul(2); err == nil {
+		t.Error("Mul overflowed 128 bits without an error")
+	}
+	if _, err := RawFromBigInt(big.NewInt(-1)); err == nil {
+		t.Error("RawFromBigInt accepted a negative amount")
+	}
+
+	sum, err := NewRaw(40).Add(NewRaw(2))
+	if err != nil || sum.Cmp(NewRaw(42)) != 0 {
+		t.Errorf("40 + 2 = %s, %v", sum, err)
+	}
+
+	diff, err := max.Sub(max)
+	if err != nil || !diff.IsZero() {
+		t.Errorf("max - max = %s, %v", diff, err)
+	}
+
+	decoded, err := RawFromBytes(max.Bytes())
+	if err != nil || decoded.Cmp(max) != 0 {
+		t.Errorf("RawFromBytes(max.Bytes()) = %s, %v", decoded, err)
+	}
+	if _, err := RawFromBytes(make([]byte, 17)); err == nil {
+		t.Error("RawFromBytes accepted 17 bytes")
+	}
+
+	var zero Raw
+	if !zero.IsZero() || zero.String() != "0" || len(zero.Bytes()) != 16 {
+		t.Errorf("zero value = %s", zero)
+	}
+}
+
+func TestRawJSON(t *testing.T) {
+	var r Raw
+	if err := json.Unmarshal([]byte(`"`+maxRawString+`"`), &r); err != nil || r.String() != maxRawString {
+		t.Fatalf("Unmarshal(max) = %s, %v", r, err)
+	}
+
+	encoded, err := json.Marshal(r)
+	if err != nil || string(encoded) != `"`+maxRawString+`"` {
+		t.Fatalf("Marshal = %s, %v", encoded, err)
+	}
+
+	// null leaves the value alone, like it does for other types
+	if err := json.Unmarshal([]byte("null"), &r); err != nil || r.String() != maxRawString {
+		t.Fatalf("Unmarshal(null) = %s, %v", r, err)
+	}
+
+	for _, bad := range []string{`1000`, `"1000`, `1000"`, `""`, `"-1"`, `"1.5"`, `"abc"`, `"340282366920938463463374607431768211456"`, `true`, `{}`} {
+		var r Raw
+		if err := json.Unmarshal([]byte(bad), &r); err == nil {
+			t.Errorf("Unmarshal(%s) = %s, want an error", bad, r)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package nanoproto
+
+import (
+	"errors"
+	"fmt"
+)
+
+// GF(256) arithmetic over the polynomial x^8 + x^4 + x^3 + x^2 + 1 (0x11D)
+var gfExp, gfLog = func() ([512]byte, [256]byte) {
+	var exp [512]byte
+	var log [256]byte
+
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = byte(i)
+
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+
+	// Duplicate the table so gfMul can skip the modulo
+	for i := 255; i < 512; i++ {
+		exp[i] = exp[i-255]
+	}
+
+	return exp, log
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// ReedSolomon is a systematic erasure code: the data shards are kept as-is and parity shards are added,
+// so the original data can be rebuilt from any dataShards of the dataShards+parityShards shards.
+type ReedSolomon struct {
+	dataShards   int
+	parityShards int
+	parity       [][]byte // Cauchy matrix, one row per parity shard
+}
+
+func NewReedSolomon(dataShards, parityShards int) (*ReedSolomon, error) {
+	if dataShards < 1 || parityShards < 0 {
+		return nil, errors.New("need at least one data shard and no negative parity shards")
+	}
+	if dataShards+parityShards > 256 {
+		return nil, errors.New("at most 256 shards are supported")
+	}
+
+	// Row i, column j is 1 / (x_i + y_j) with x_i = dataShards+i and y_j = j, every square submatrix of it is invertible
+	parity := make([][]byte, parityShards)
+	for i := range parity {
+		parity[i] = make([]byte, dataShards)
+		for j := range parity[i] {
+			parity[i][j] = gfInv(byte(dataShards+i) ^ byte(j))
+		}
+	}
+
+	return &ReedSolomon{dataShards, parityShards, parity}, nil
+}
+
+// Encode splits data into equally sized data shards and appends the parity shards.
+func (rs *ReedSolomon) Encode(data []byte) [][]byte {
+	shards := splitShards(data, rs.dataShards)
+	shardSize := len(shards[0])
+
+	for _, row := range rs.parity {
+		shards = append(shards, rs.combine(row, shards[:rs.dataShards], shardSize))
+	}
+
+	return shards
+}
+
+// Reconstruct fills in the missing (nil) shards in place. At least dataShards shards must be present.
+func (rs *ReedSolomon) Reconstruct(shards [][]byte) error {
+	if len(shards) != rs.dataShards+rs.parityShards {
+		return fmt.Errorf("expected %d shards, got %d", rs.dataShards+rs.parityShards, len(shards))
+	}
+
+	shardSize := -1
+	var present []int
+
+	for i, shard := range shards {
+		if shard == nil {
+			continue
+		}
+		if shardSize >= 0 && len(shard) != shardSize {
+			return errors.New("shards must all be the same size")
+		}
+
+		shardSize = len(shard)
+		present = append(present, i)
+	}
+
+	if len(present) < rs.dataShards {
+		return fmt.Errorf("too few shards to reconstruct: have %d, need %d", len(present), rs.dataShards)
+	}
+
+	// Express the first dataShards available shards in terms of the data shards and invert that system
+	present = present[:rs.dataShards]
+	matrix := make([][]byte, rs.dataShards)
+	inputs := make([][]byte, rs.dataShards)
+
+	for i, index := range present {
+		matrix[i] = rs.row(index)
+		inputs[i] = shards[index]
+	}
+
+	inverse, err := gfInvert(matrix)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < rs.dataShards; i++ {
+		if shards[i] == nil {
+			shards[i] = rs.combine(inverse[i], inputs, shardSize)
+		}
+	}
+
+	for i, row := range rs.parity {
+		if shards[rs.dataShards+i] == nil {
+			shards[rs.dataShards+i] = rs.combine(row, shards[:rs.dataShards], shardSize)
+		}
+	}
+
+	return nil
+}
+
+// row returns the encoding matrix row producing the shard at index.
+func (rs *ReedSolomon) row(index int) []byte {
+	if index >= rs.dataShards {
+		return rs.parity[index-rs.dataShards]
+	}
+
+	row := make([]byte, rs.dataShards)
+	row[index] = 1
+	return row
+}
+
+// combine computes the linear combination sum(coefficients[j] * shards[j]) byte by byte.
+func (rs *ReedSolomon) combine(coefficients []byte, shards [][]byte, shardSize int) []byte {
+	out := make([]byte, shardSize)
+
+	for j, c := range coefficients {
+		if c == 0 {
+			continue
+		}
+		for k, b := range shards[j] {
+			out[k] ^= gfMul(c, b)
+		}
+	}
+
+	return out
+}
+
+// gfInvert inverts a square matrix over GF(256) using Gauss-Jordan elimination.
+func gfInvert(matrix [][]byte) ([][]byte, error) {
+	n := len(matrix)
+	work := make([][]byte, n)
+
+	for i := range matrix {
+		work[i] = make([]byte, 2*n)
+		copy(work[i], matrix[i])
+		work[i][n+i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for pivot < n && work[pivot][col] == 0 {
+			pivot++
+		}
+		if pivot == n {
+			return nil, errors.New("matrix is singular")
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+
+		scale := gfInv(work[col][col])
+		for k := range work[col] {
+			work[col][k] = gfMul(work[col][k], scale)
+		}
+
+		for row := 0; row < n; row++ {
+			factor := work[row][col]
+			if row == col || factor == 0 {
+				continue
+			}
+			for k := range work[row] {
+				work[row][k] ^= gfMul(factor, work[col][k])
+			}
+		}
+	}
+
+	inverse := make([][]byte, n)
+	for i := range work {
+		inverse[i] = work[i][n:]
+	}
+
+	return inverse, nil
+}
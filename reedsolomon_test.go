@@ -0,0 +1,88 @@
+package nanoproto
+
+import (
+	"bytes"
+	"math/bits"
+	"math/rand"
+	"testing"
+)
+
+var reedSolomonLayouts = []struct{ data, parity int }{
+	{1, 1}, {1, 3}, {2, 1}, {3, 2}, {4, 3}, {5, 5}, {8, 4}, {10, 4},
+}
+
+func TestReedSolomonErasures(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	for _, layout := range reedSolomonLayouts {
+		rs, err := NewReedSolomon(layout.data, layout.parity)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Not a multiple of the shard count so the last data shard is padded
+		data := make([]byte, 97*layout.data+5)
+		random.Read(data)
+
+		encoded := rs.Encode(data)
+		total := layout.data + layout.parity
+
+		if len(encoded) != total {
+			t.Fatalf("%d+%d: Encode returned %d shards", layout.data, layout.parity, len(encoded))
+		}
+		if got := joinShards(encoded[:layout.data], uint64(len(data))); !bytes.Equal(got, data) {
+			t.Fatalf("%d+%d: data shards don't hold the data", layout.data, layout.parity)
+		}
+
+		// Every combination of erased shards
+		for mask := 1; mask < 1<<total; mask++ {
+			erased := bits.OnesCount(uint(mask))
+
+			shards := make([][]byte, total)
+			for i := range shards {
+				if mask&(1<<i) == 0 {
+					shards[i] = append([]byte(nil), encoded[i]...)
+				}
+			}
+
+			err := rs.Reconstruct(shards)
+
+			if erased > layout.parity {
+				if err == nil {
+					t.Fatalf("%d+%d: reconstructed with %d shards erased (mask %b)", layout.data, layout.parity, erased, mask)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%d+%d: mask %b: %v", layout.data, layout.parity, mask, err)
+			}
+
+			for i := range shards {
+				if !bytes.Equal(shards[i], encoded[i]) {
+					t.Fatalf("%d+%d: mask %b: shard %d differs after reconstruction", layout.data, layout.parity, mask, i)
+				}
+			}
+		}
+	}
+}
+
+func TestReedSolomonInvalid(t *testing.T) {
+	for _, layout := range []struct{ data, parity int }{{0, 1}, {1, -1}, {200, 57}} {
+		if _, err := NewReedSolomon(layout.data, layout.parity); err == nil {
+			t.Errorf("NewReedSolomon(%d, %d) accepted the layout", layout.data, layout.parity)
+		}
+	}
+
+	rs, _ := NewReedSolomon(3, 2)
+	shards := rs.Encode([]byte("hello, world"))
+
+	if err := rs.Reconstruct(shards[:4]); err == nil {
+		t.Error("Reconstruct accepted the wrong shard count")
+	}
+
+	shards[0] = nil
+	shards[1] = shards[1][:1]
+	if err := rs.Reconstruct(shards); err == nil {
+		t.Error("Reconstruct accepted shards of different sizes")
+	}
+}
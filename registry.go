@@ -0,0 +1,154 @@
+package nanoproto
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var ErrUnknownType = errors.New("unknown message type")
+
+// Registry maps schema IDs carried in frame headers to Go types and their decoders.
+// ID 0 is reserved for untyped messages. Header frames begin with BEGIN_HEADER, which readers predating
+// frame headers don't recognise, so they need an updated reader to see typed messages at all.
+type Registry struct {
+	SkipUnknown bool // Leave messages with unregistered IDs out of GetTyped instead of reporting them
+
+	mu     sync.RWMutex
+	byID   map[uint64]registryEntry
+	byType map[reflect.Type]uint64
+}
+
+type registryEntry struct {
+	name   string
+	decode func([]byte) (any, error)
+}
+
+// TypedMessage is a message decoded according to the schema ID in its frame header.
+type TypedMessage struct {
+	Message
+	TypeName string
+	Value    any   // Decoded value, nil when Err is set
+	Err      error // ErrUnknownType or the decoding error
+}
+
+func NewRegistry() *Registry {
+	return &Registry{byID: map[uint64]registryEntry{}, byType: map[reflect.Type]uint64{}}
+}
+
+// Register maps id to T, decoded with Unmarshal. GetTyped returns the values as T.
+// T must be a struct type, PutTyped accepts both values and pointers of it.
+func Register[T any](r *Registry, id uint64) error {
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot register %s, only struct types can be registered", t)
+	}
+
+	if err := r.RegisterDecoder(id, t.String(), func(data []byte) (any, error) {
+		var value T
+		err := Unmarshal(data, &value)
+		return value, err
+	}); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.byType[t] = id
+	return nil
+}
+
+// RegisterDecoder maps id to a custom decoder, for types that don't use the built-in protobuf codec.
+func (r *Registry) RegisterDecoder(id uint64, name string, decode func([]byte) (any, error)) error {
+	if id == 0 {
+		return errors.New("schema ID 0 is reserved for untyped messages")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.byID[id]; ok {
+		return fmt.Errorf("schema ID %d is already registered to %s", id, existing.name)
+	}
+
+	r.byID[id] = registryEntry{name, decode}
+	return nil
+}
+
+// TypeID returns the schema ID registered for the type of v.
+func (r *Registry) TypeID(v any) (uint64, bool) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.byType[t]
+	return id, ok
+}
+
+// Decode decodes a message using the decoder registered for its schema ID.
+func (r *Registry) Decode(message Message) TypedMessage {
+	r.mu.RLock()
+	entry, ok := r.byID[message.Type]
+	r.mu.RUnlock()
+
+	typed := TypedMessage{Message: message}
+	if !ok {
+		typed.Err = fmt.Errorf("%w %d", ErrUnknownType, message.Type)
+		return typed
+	}
+
+	typed.TypeName = entry.name
+	typed.Value, typed.Err = entry.decode(message.Data)
+	if typed.Err != nil {
+		typed.Value = nil
+	}
+
+	return typed
+}
+
+// PutTyped encodes v with Marshal and stores it with the schema ID registered for its type.
+func (s *NanoDataStorage) PutTyped(registry *Registry, v any) error {
+	id, ok := registry.TypeID(v)
+	if !ok {
+		return fmt.Errorf("%T is not registered", v)
+	}
+
+	data, err := Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.putFramed(id, data)
+	return err
+}
+
+// GetTyped decodes every typed message stored on address. Untyped messages are left out.
+func (s *NanoDataStorage) GetTyped(address *string, registry *Registry) ([]TypedMessage, error) {
+	messages, err := s.GetDataRefs(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var typed []TypedMessage
+
+	for _, message := range messages {
+		if message.Type == 0 {
+			continue
+		}
+
+		decoded := registry.Decode(message)
+		if registry.SkipUnknown && errors.Is(decoded.Err, ErrUnknownType) {
+			continue
+		}
+
+		typed = append(typed, decoded)
+	}
+
+	return typed, nil
+}
@@ -0,0 +1,105 @@
+package nanoproto
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegisterRejects(t *testing.T) {
+	registry := NewRegistry()
+
+	if err := Register[protoTest1](registry, 0); err == nil {
+		t.Error("registered schema ID 0")
+	}
+	if err := Register[*protoTest1](registry, 1); err == nil {
+		t.Error("registered a pointer type")
+	}
+	if err := Register[string](registry, 1); err == nil {
+		t.Error("registered a non-struct type")
+	}
+
+	if err := Register[protoTest1](registry, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register[protoTest2](registry, 1); err == nil {
+		t.Error("registered schema ID 1 twice")
+	}
+	if err := registry.RegisterDecoder(1, "custom", func([]byte) (any, error) { return nil, nil }); err == nil {
+		t.Error("registered a decoder for a taken schema ID")
+	}
+
+	// Rejected registrations leave nothing behind
+	if id, ok := registry.TypeID(protoTest2{}); ok {
+		t.Errorf("protoTest2 has ID %d after a failed registration", id)
+	}
+	if id, ok := registry.TypeID(&protoTest1{}); !ok || id != 1 {
+		t.Errorf("TypeID(*protoTest1) = %d, %v", id, ok)
+	}
+}
+
+func TestPutTypedGetTyped(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+	storage := NewNanoDataStorage(rpc, &address, &privateKey)
+
+	writer := NewRegistry()
+	for _, err := range []error{Register[protoTest1](writer, 1), Register[protoTest2](writer, 2), Register[protoScalars](writer, 3)} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := storage.PutTyped(writer, protoTest1{A: 150}); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.PutTyped(writer, &protoTest2{B: "testing"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.PutTyped(writer, protoScalars{Sint: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.PutData([]byte("untyped")); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.PutTyped(writer, protoRepeated{}); err == nil {
+		t.Error("stored an unregistered type")
+	}
+
+	// The reader doesn't know schema 3
+	reader := NewRegistry()
+	Register[protoTest1](reader, 1)
+	Register[protoTest2](reader, 2)
+
+	typed, err := storage.GetTyped(&address, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(typed) != 3 {
+		t.Fatalf("read %d typed messages, want 3", len(typed))
+	}
+
+	if value, ok := typed[0].Value.(protoTest1); !ok || value.A != 150 || typed[0].Type != 1 || typed[0].TypeName != "nanoproto.protoTest1" {
+		t.Errorf("first message %+v", typed[0])
+	}
+	if value, ok := typed[1].Value.(protoTest2); !ok || value.B != "testing" {
+		t.Errorf("second message %+v", typed[1])
+	}
+	if !errors.Is(typed[2].Err, ErrUnknownType) || typed[2].Value != nil || typed[2].Type != 3 {
+		t.Errorf("third message %+v, want an unknown type", typed[2])
+	}
+
+	reader.SkipUnknown = true
+	if typed, err := storage.GetTyped(&address, reader); err != nil || len(typed) != 2 {
+		t.Errorf("read %d typed messages (%v) skipping unknown types, want 2", len(typed), err)
+	}
+}
+
+func TestRegistryDecodeError(t *testing.T) {
+	registry := NewRegistry()
+	Register[protoTest1](registry, 1)
+
+	typed := registry.Decode(Message{Type: 1, Data: []byte{0x08}}) // Field 1 without its varint
+	if typed.Err == nil || typed.Value != nil {
+		t.Errorf("decoded %+v from a truncated message", typed)
+	}
+}
@@ -0,0 +1,40 @@
+package nanoproto
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// SetRepresentative makes every write end with a change back to representative, so a funded account only
+// delegates its weight to data addresses while an upload is in progress. A failed write is followed by
+// RestoreRepresentative. Readers skip the restore block, it lies outside any frame. An empty address turns it off.
+func (s *NanoDataStorage) SetRepresentative(representative string) error {
+	if representative != "" {
+		if _, err := nanoAddressToPublicKey(representative); err != nil {
+			return fmt.Errorf("invalid representative: %v", err)
+		}
+	}
+
+	s.representative = representative
+	return nil
+}
+
+// RestoreRepresentative publishes a change block back to the configured representative, e.g. after an upload
+// was interrupted. It is preceded by an ABORT_FRAME block so readers drop the unfinished message, and when there
+// is none the abort block lies outside any frame and is skipped too. Returns the hash of the restore block.
+func (s *NanoDataStorage) RestoreRepresentative() (string, error) {
+	if s.representative == "" {
+		return "", errors.New("no representative configured")
+	}
+
+	pubKey, _ := nanoAddressToPublicKey(s.representative)
+	chunk, _ := hex.DecodeString(pubKey)
+
+	hashes, err := s.putMessage(RepresentativeCarrier{}, [][]byte{abortChunk(), chunk})
+	if err != nil {
+		return "", err
+	}
+
+	return hashes[1], nil
+}
@@ -0,0 +1,99 @@
+package nanoproto
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how RPC.Call retries failed requests.
+type RetryPolicy struct {
+	MaxAttempts      int           // Total attempts per call, values below 2 disable retries
+	BaseDelay        time.Duration // Delay before the second attempt, doubled on every retry
+	MaxDelay         time.Duration // Upper bound for a single delay
+	RetryStatusCodes []int         // HTTP status codes worth retrying
+	RetryNodeErrors  []string      // Node error messages worth retrying (case-insensitive substrings)
+}
+
+// NodeError is an {"error": "..."} response returned by the node.
+type NodeError struct {
+	Action  string
+	Message string
+}
+
+func (e *NodeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Action, e.Message)
+}
+
+// StatusError is returned when the node responds with a non-2xx HTTP status.
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+	RetryAfter time.Duration // From the Retry-After header, 0 when absent
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status %d", e.StatusCode)
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:      4,
+		BaseDelay:        500 * time.Millisecond,
+		MaxDelay:         10 * time.Second,
+		RetryStatusCodes: []int{408, 429, 500, 502, 503, 504},
+		RetryNodeErrors:  []string{"too many requests", "rate limit", "timeout", "timed out", "work generation", "unable to process"},
+	}
+}
+
+// NoRetry makes exactly one attempt per call.
+func NoRetry() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 1}
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	var nodeErr *NodeError
+	if errors.As(err, &nodeErr) {
+		message := strings.ToLower(nodeErr.Message)
+		for _, class := range p.RetryNodeErrors {
+			if strings.Contains(message, strings.ToLower(class)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return slices.Contains(p.RetryStatusCodes, statusErr.StatusCode)
+	}
+
+	// Transport failures (refused connections, resets, timeouts) are always worth another try
+	return errors.Is(err, errTransport)
+}
+
+// backoff returns the delay before the given retry (1 = first retry) using exponential backoff with jitter.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay << (retry - 1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+
+	// Sleep somewhere between half and the full delay so parallel writers don't retry in lockstep
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+var errTransport = errors.New("transport error")
+
+func isNodeError(err error, message string) bool {
+	var nodeErr *NodeError
+	return errors.As(err, &nodeErr) && strings.HasPrefix(nodeErr.Message, message)
+}
@@ -0,0 +1,143 @@
+package nanoproto
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) {
+	policy := DefaultRetryPolicy()
+
+	for _, test := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limit message", &NodeError{"process", "Too many requests"}, true},
+		{"work generation", &NodeError{"work_generate", "Work generation cancelled"}, true},
+		{"fork", &NodeError{"process", "Fork"}, false},
+		{"wrapped node error", fmt.Errorf("reading: %w", &NodeError{"account_info", "timeout"}), true},
+		{"retried status", &StatusError{StatusCode: 503}, true},
+		{"rate limited status", &StatusError{StatusCode: 429, RetryAfter: time.Second}, true},
+		{"client error status", &StatusError{StatusCode: 404}, false},
+		{"transport", fmt.Errorf("%w: connection refused", errTransport), true},
+		{"other", errors.New("invalid json"), false},
+	} {
+		if got := policy.retryable(test.err); got != test.want {
+			t.Errorf("%s: retryable = %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	// Classes are whatever the policy lists
+	if NoRetry().retryable(&StatusError{StatusCode: 503}) || NoRetry().retryable(&NodeError{"process", "timeout"}) {
+		t.Error("NoRetry classifies errors as retryable")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for _, test := range []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{4, 400 * time.Millisecond, 800 * time.Millisecond},
+		{5, 500 * time.Millisecond, time.Second},  // Capped
+		{70, 500 * time.Millisecond, time.Second}, // Shifted past the range of Duration
+	} {
+		seen := map[time.Duration]bool{}
+		for i := 0; i < 100; i++ {
+			delay := policy.backoff(test.retry)
+			if delay < test.min || delay > test.max {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", test.retry, delay, test.min, test.max)
+			}
+			seen[delay] = true
+		}
+
+		if len(seen) < 2 {
+			t.Errorf("backoff(%d) always waits the same, want jitter", test.retry)
+		}
+	}
+
+	if delay := NoRetry().backoff(1); delay != 0 {
+		t.Errorf("NoRetry waits %v", delay)
+	}
+}
+
+func TestRetryAttempts(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	for _, test := range []struct {
+		name   string
+		policy RetryPolicy
+		want   int32
+	}{
+		{"no retry", NoRetry(), 1},
+		{"three attempts", RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, RetryStatusCodes: []int{503}}, 3},
+		{"not retryable", RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, RetryStatusCodes: []int{502}}, 1},
+	} {
+		calls.Store(0)
+
+		rpc := NewRPC(server.URL)
+		rpc.SetRetryPolicy(test.policy)
+
+		var statusErr *StatusError
+		if _, err := rpc.BlockCount(); !errors.As(err, &statusErr) || statusErr.StatusCode != 503 {
+			t.Errorf("%s: got %v, want the 503", test.name, err)
+		}
+		if calls.Load() != test.want {
+			t.Errorf("%s: %d calls, want %d", test.name, calls.Load(), test.want)
+		}
+	}
+}
+
+// A retry after a lost response resubmits a block the node already has, which must count as published.
+func TestProcessBlockOldBlock(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+
+	open := node.chain(address)[0]
+	block := NewChangeBlock(address, open.Hash, address, open.Balance)
+	if err := block.Sign(privateKey); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := block.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		hash, err := rpc.ProcessBlock(block.Map(), "change")
+		if err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+		if hash != want {
+			t.Errorf("attempt %d returned %s, want %s", i+1, hash, want)
+		}
+	}
+
+	if chain := node.chain(address); len(chain) != 2 {
+		t.Errorf("chain has %d blocks, want the block published once", len(chain))
+	}
+
+	// Other node errors still fail
+	node.hook = func(action string, request map[string]interface{}) (interface{}, bool) {
+		return map[string]string{"error": "Fork"}, true
+	}
+	if _, err := rpc.ProcessBlock(block.Map(), "change"); !isNodeError(err, "Fork") {
+		t.Errorf("got %v, want the fork", err)
+	}
+}
@@ -0,0 +1,154 @@
+package nanoproto
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// SetAuthorKey signs every message written from now on with a separate Nano private key,
+// so readers of a shared account can tell who wrote it. An empty key turns signing off.
+func (s *NanoDataStorage) SetAuthorKey(privateKey string) error {
+	if privateKey != "" {
+		if _, err := PrivateKeyToAddress(privateKey); err != nil {
+			return fmt.Errorf("invalid author key: %v", err)
+		}
+	}
+
+	s.authorKey = privateKey
+	return nil
+}
+
+// putFramed stores data with the given schema ID, signed when an author key is set, and returns where it went.
+func (s *NanoDataStorage) putFramed(typeID uint64, data []byte) (MessageRef, error) {
+	header := frameHeader{Type: typeID}
+
+	if s.authorKey != "" {
+		if err := header.sign(*s.address, data, s.authorKey); err != nil {
+			return MessageRef{}, err
+		}
+	}
+
+	// An empty header encodes to nothing, leaving a plain BEGIN_PROTOBUF frame
+	encoded, err := Marshal(header)
+	if err != nil {
+		return MessageRef{}, err
+	}
+
+	carrier := s.carrier(*s.address)
+	chunks := frameChunks(encoded, data)
+	frameBlocks := len(chunks)
+
+	restore := s.restores(carrier)
+	if restore {
+		pubKey, _ := nanoAddressToPublicKey(s.representative)
+		chunk, _ := hex.DecodeString(pubKey)
+		chunks = append(chunks, chunk)
+	}
+
+	hashes, err := s.putMessage(carrier, chunks)
+	if err != nil && restore && len(hashes) > 0 {
+		// Don't leave the account's voting weight on a data address
+		if _, restoreErr := s.RestoreRepresentative(); restoreErr != nil {
+			return MessageRef{}, errors.Join(err, fmt.Errorf("failed to restore representative: %v", restoreErr))
+		}
+	}
+	if err != nil {
+		return MessageRef{}, err
+	}
+
+	return MessageRef{Account: *s.address, FirstHash: hashes[0], LastHash: hashes[frameBlocks-1]}, nil
+}
+
+// restores reports whether writes through carrier end with a block restoring the representative.
+// Only the representative carrier moves the account's weight away.
+func (s *NanoDataStorage) restores(carrier Carrier) bool {
+	_, delegates := carrier.(RepresentativeCarrier)
+	return delegates && s.representative != ""
+}
+
+// EstimateBlocks returns how many blocks PutData publishes for a payload of size bytes, counting the frame
+// header of an author signature and the block restoring the representative.
+func (s *NanoDataStorage) EstimateBlocks(size int) int {
+	header := frameHeader{}
+	if s.authorKey != "" {
+		header.Signer = make([]byte, 32)
+		header.Signature = make([]byte, 64)
+	}
+
+	encoded, err := Marshal(header)
+	if err != nil {
+		return -1
+	}
+
+	carrier := Carrier(RepresentativeCarrier{})
+	if s.address != nil {
+		carrier = s.carrier(*s.address)
+	}
+
+	blocks := estimateBlocks(encoded, size)
+	if blocks > 0 && s.restores(carrier) {
+		blocks++
+	}
+
+	return blocks
+}
+
+// signedDigest is what authors sign: blake2b-256 of the storage account public key, the uvarint schema ID
+// and the payload, so a signed message can't be replayed on another account or under a different type.
+// It isn't bound to a position, the same frame can be written again on the same account.
+func (h *frameHeader) signedDigest(account string, data []byte) ([]byte, error) {
+	pubKey, err := nanoAddressToPublicKey(account)
+	if err != nil {
+		return nil, err
+	}
+
+	accountKey, _ := hex.DecodeString(pubKey)
+
+	hasher, _ := blake2b.New256(nil)
+	hasher.Write(accountKey)
+	hasher.Write(AppendVarint(nil, h.Type))
+	hasher.Write(data)
+	return hasher.Sum(nil), nil
+}
+
+func (h *frameHeader) sign(account string, data []byte, privateKey string) error {
+	ed := NewEd25519()
+
+	keys, err := ed.GenerateKeys(privateKey)
+	if err != nil {
+		return err
+	}
+
+	digest, err := h.signedDigest(account, data)
+	if err != nil {
+		return err
+	}
+
+	privKeyBytes, _ := hex.DecodeString(privateKey)
+	signature, err := ed.Sign(digest, privKeyBytes)
+	if err != nil {
+		return err
+	}
+
+	h.Signer, _ = hex.DecodeString(keys["publicKey"])
+	h.Signature = signature
+	return nil
+}
+
+// verify returns the signer address of a frame signed for account. Unsigned frames verify with an empty signer.
+func (h *frameHeader) verify(account string, data []byte) (string, bool) {
+	if h.Signer == nil && h.Signature == nil {
+		return "", true
+	}
+
+	digest, err := h.signedDigest(account, data)
+	if err != nil || !NewEd25519().Verify(digest, h.Signer, h.Signature) {
+		return "", false
+	}
+
+	signer, err := publicKeyToNanoAddress(h.Signer)
+	return signer, err == nil
+}
@@ -0,0 +1,110 @@
+package nanoproto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAuthorSignedMessages(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+	authorKey, author, _ := DeriveAccount(strings.Repeat("A1", 32), 0)
+
+	storage := NewNanoDataStorage(rpc, &address, &privateKey)
+	if err := storage.PutData([]byte("unsigned")); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.SetAuthorKey(authorKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.PutData([]byte("signed")); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := storage.GetDataRefs(&address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("read %d messages, want 2", len(messages))
+	}
+	if string(messages[0].Data) != "unsigned" || messages[0].Signer != "" {
+		t.Errorf("unsigned message read as %q by %q", messages[0].Data, messages[0].Signer)
+	}
+	if string(messages[1].Data) != "signed" || messages[1].Signer != author {
+		t.Errorf("signed message read as %q by %q, want %s", messages[1].Data, messages[1].Signer, author)
+	}
+
+	if err := storage.SetAuthorKey("not a key"); err == nil {
+		t.Error("SetAuthorKey accepted an invalid key")
+	}
+}
+
+func TestFrameSignature(t *testing.T) {
+	authorKey, author, _ := DeriveAccount(strings.Repeat("A1", 32), 0)
+	otherKey, _, _ := DeriveAccount(strings.Repeat("A1", 32), 1)
+	_, account, _ := DeriveAccount(strings.Repeat("5E", 32), 0)
+	_, otherAccount, _ := DeriveAccount(strings.Repeat("5E", 32), 1)
+
+	data := []byte("payload")
+	header := frameHeader{Type: 7}
+	if err := header.sign(account, data, authorKey); err != nil {
+		t.Fatal(err)
+	}
+
+	if signer, ok := header.verify(account, data); !ok || signer != author {
+		t.Fatalf("verify = %q, %v, want %s", signer, ok, author)
+	}
+
+	if _, ok := header.verify(account, []byte("payloaD")); ok {
+		t.Error("tampered payload verified")
+	}
+
+	retyped := header
+	retyped.Type = 8
+	if _, ok := retyped.verify(account, data); ok {
+		t.Error("signature verified under another schema ID")
+	}
+
+	if _, ok := header.verify(otherAccount, data); ok {
+		t.Error("signature verified on another account")
+	}
+
+	// Claiming another author with the original signature
+	other := frameHeader{Type: 7}
+	other.sign(account, data, otherKey)
+	other.Signature = header.Signature
+	if _, ok := other.verify(account, data); ok {
+		t.Error("signature verified for the wrong signer")
+	}
+}
+
+// A signed frame copied to another account is dropped there.
+func TestSignedFrameReplay(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+	otherAddress, otherPrivateKey := node.testAccount(t, 1)
+	authorKey, _, _ := DeriveAccount(strings.Repeat("A1", 32), 0)
+
+	data := []byte("only valid on the first account")
+	header := frameHeader{}
+	if err := header.sign(address, data, authorKey); err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := Marshal(header)
+
+	for _, account := range []struct{ address, privateKey string }{{address, privateKey}, {otherAddress, otherPrivateKey}} {
+		storage := NewNanoDataStorage(rpc, &account.address, &account.privateKey)
+		if _, err := storage.putMessage(RepresentativeCarrier{}, frameChunks(encoded, data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reader := NewNanoDataStorage(rpc, nil, nil)
+	if messages, err := reader.GetData(&address); err != nil || len(messages) != 1 {
+		t.Fatalf("original account: %d messages, %v", len(messages), err)
+	}
+	if messages, err := reader.GetData(&otherAddress); err != nil || len(messages) != 0 {
+		t.Fatalf("replayed frame read back on another account: %d messages, %v", len(messages), err)
+	}
+}
@@ -1,73 +1,181 @@
 package nanoproto
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
 
 type NanoDataStorage struct {
-	rpc        *RPC
-	address    *string
-	privateKey *string
+	rpc            *RPC
+	address        *string
+	privateKey     *string
+	cache          HistoryCache
+	authorKey      string
+	verify         bool
+	quorum         *quorum
+	representative string
+	carriersMu     sync.RWMutex
+	carriers       map[string]Carrier
 }
 
 func NewNanoDataStorage(rpc *RPC, address *string, privateKey *string) *NanoDataStorage {
-	return &NanoDataStorage{rpc, address, privateKey}
+	return &NanoDataStorage{rpc: rpc, address: address, privateKey: privateKey}
 }
 
 // Your RPC must provide raw account history retrieval abilities for this method (rpc.nano.to won't work :/)
 func (s *NanoDataStorage) GetData(address *string) ([][]byte, error) {
-	received, _ := s.rpc.History(*address)
-	bytes := []string{}
+	messages, err := s.GetDataRefs(address)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, ahi := range received {
-		pubKey, err := nanoAddressToPublicKey(ahi.Representative)
-		if err != nil {
-			return nil, err
-		}
+	data := make([][]byte, 0, len(messages))
 
-		bytes = append(bytes, pubKey)
+	for _, message := range messages {
+		data = append(data, message.Data)
 	}
 
-	data := getBuffers(bytes)
-
 	return data, nil
 }
 
 // Your RPC must provide account info, work generation, and block processing abilities for this method (rpc.nano.to may work, untested)
 func (s *NanoDataStorage) PutData(data []byte) error {
-	addresses := CreateMessage(data)
+	_, err := s.putFramed(0, data)
+	return err
+}
 
-	for _, address := range addresses {
-		accountInfo, err := s.rpc.AccountInfo(*s.address)
-		if err != nil {
-			fmt.Errorf(err.Error())
-			break
+// maxReconciles bounds how many times a single write resyncs with the node after a fork or gap.
+const maxReconciles = 5
+
+// putMessage publishes one block per chunk with the given carrier and returns the block hashes in order.
+// The frontier is tracked locally from the computed block hashes, so a lagging node can't make us sign against a stale one.
+func (s *NanoDataStorage) putMessage(carrier Carrier, chunks [][]byte) ([]string, error) {
+	accountInfo, err := s.rpc.AccountInfo(*s.address)
+	if err != nil {
+		return nil, err
+	}
+
+	start := accountInfo.Frontier
+	frontier := start
+	blocks := make([]*StateBlock, 0, len(chunks))
+	hashes := make([]string, 0, len(chunks))
+	reconciles := 0
+
+	for i := 0; i < len(chunks); i++ {
+		// Carriers may move funds or keep the representative, so each block builds on the state left by the previous one
+		representative, balance := accountInfo.Representative, accountInfo.Balance
+		if len(blocks) > 0 {
+			representative, balance = blocks[len(blocks)-1].Representative, blocks[len(blocks)-1].Balance
 		}
 
-		// fmt.Printf("%+v\n", accountInfo)
+		work, err := s.rpc.WorkGenerate(frontier)
+		if err != nil {
+			return hashes, err
+		}
 
-		work, err := s.rpc.WorkGenerate(accountInfo.Frontier)
+		block, err := carrier.Block(*s.address, frontier, representative, balance, chunks[i])
 		if err != nil {
-			fmt.Errorf(err.Error())
-			break
+			return hashes, err
 		}
 
-		// fmt.Println(work)
+		if err := block.Sign(*s.privateKey); err != nil {
+			return hashes, err
+		}
+
+		block.Work = work
+
+		// Retries inside Call resubmit the exact same signed block, an "Old block" answer counts as success
+		_, err = s.rpc.ProcessBlock(block.Map(), carrier.Subtype())
+		if (isNodeError(err, "Fork") || isNodeError(err, "Gap previous")) && reconciles < maxReconciles {
+			reconciles++
+
+			hash, err := block.Hash()
+			if err != nil {
+				return hashes, err
+			}
+
+			keep, err := s.reconcile(start, append(hashes[:len(hashes):len(hashes)], hash))
+			if err != nil {
+				return hashes, err
+			}
+
+			if keep > len(hashes) {
+				// The block reached the node some other way
+				blocks, hashes, frontier = append(blocks, block), append(hashes, hash), hash
+				continue
+			}
+
+			blocks, hashes, frontier = blocks[:keep], hashes[:keep], start
+			if keep > 0 {
+				frontier = hashes[keep-1]
+			}
 
-		block, err := s.rpc.ChangeRepresentativeBlock(*s.privateKey, *s.address, address, work, accountInfo.Frontier, accountInfo.Balance)
+			i = keep - 1 // Resume with the chunk following the kept blocks
+			continue
+		}
 		if err != nil {
-			fmt.Errorf(err.Error())
-			break
+			return hashes, fmt.Errorf("failed to process block: %v", err)
 		}
 
-		// fmt.Printf("%+v\n", block)
-
-		_, err = s.rpc.ProcessChangeRepBlock(block)
+		hash, err := block.Hash()
 		if err != nil {
-			fmt.Errorf(err.Error())
-			break
+			return hashes, err
+		}
+
+		blocks = append(blocks, block)
+		hashes = append(hashes, hash)
+		frontier = hash
+	}
+
+	return hashes, nil
+}
+
+// reconcile works out where to resume a write after the node reported a fork or a gap. chain holds the hashes
+// published so far followed by the rejected block, and it returns how many of them to keep.
+func (s *NanoDataStorage) reconcile(start string, chain []string) (int, error) {
+	position := func(head string) int {
+		if strings.EqualFold(head, start) {
+			return 0
+		}
+
+		for i, hash := range chain {
+			if strings.EqualFold(head, hash) {
+				return i + 1
+			}
 		}
 
-		// fmt.Println(hash)
+		return -1
 	}
 
-	return nil
+	for attempt := 1; ; attempt++ {
+		time.Sleep(s.rpc.retry.backoff(attempt))
+
+		accountInfo, err := s.rpc.AccountInfo(*s.address)
+		if err != nil {
+			return 0, err
+		}
+
+		frontier, confirmed := position(accountInfo.Frontier), position(accountInfo.ConfirmationHeightFrontier)
+
+		switch {
+		case frontier == len(chain):
+			return len(chain), nil
+		case frontier >= 0:
+			// The node is behind blocks we already published, trust our own frontier and retry the block
+			return len(chain) - 1, nil
+		case confirmed < 0:
+			return 0, fmt.Errorf("account frontier %s was not written by this upload, refusing to continue", accountInfo.ConfirmationHeightFrontier)
+		case confirmed < len(chain)-1:
+			// Unconfirmed blocks of ours lost a fork, rebuild from the cemented frontier
+			return confirmed, nil
+		}
+
+		// Another block competes with ours on the same previous. Resubmitting would fork again, so wait for the
+		// election to cement one of them
+		if attempt >= maxReconciles {
+			return 0, fmt.Errorf("block %s forked with %s and the fork was not resolved", chain[len(chain)-1], accountInfo.Frontier)
+		}
+	}
 }
@@ -0,0 +1,314 @@
+package nanoproto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+var testPayload = bytes.Repeat([]byte("nanoproto test payload "), 10)
+
+func readBack(t *testing.T, rpc *RPC, address string) []byte {
+	t.Helper()
+
+	data, err := NewNanoDataStorage(rpc, nil, nil).GetData(&address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 {
+		t.Fatalf("read %d messages, want 1", len(data))
+	}
+
+	return data[0]
+}
+
+// A node that hasn't seen our latest block yet answers with a gap, the write must keep its own frontier
+// instead of rewinding to the node's.
+func TestPutDataLaggingNode(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+
+	processed, lagging := 0, false
+	node.hook = func(action string, request map[string]interface{}) (interface{}, bool) {
+		switch {
+		case action == "process":
+			if processed++; processed == 3 {
+				lagging = true
+				return map[string]string{"error": "Gap previous block"}, true
+			}
+		case action == "account_info" && lagging:
+			lagging = false
+			chain := node.chains[address]
+			behind := chain[len(chain)-2]
+			return map[string]string{"frontier": behind.Hash, "confirmation_height_frontier": behind.Hash, "representative": behind.Representative, "balance": behind.Balance.String()}, true
+		}
+		return nil, false
+	}
+
+	if err := NewNanoDataStorage(rpc, &address, &privateKey).PutData(testPayload); err != nil {
+		t.Fatal(err)
+	}
+
+	written := len(node.chain(address)) - 1
+	if processed != written+1 {
+		t.Errorf("processed %d blocks for %d written, only the rejected block should be resubmitted", processed, written)
+	}
+	if got := readBack(t, rpc, address); !bytes.Equal(got, testPayload) {
+		t.Fatalf("read %q", got)
+	}
+}
+
+// A competing block on our previous must not be answered by resubmitting the same block: the write waits
+// for the election and continues once the competitor is rolled back.
+func TestPutDataForkResolved(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+	competitor := strings.Repeat("F0", 32)
+
+	processed, forked, checks := 0, false, 0
+	node.hook = func(action string, request map[string]interface{}) (interface{}, bool) {
+		switch {
+		case action == "process":
+			if processed++; processed == 2 {
+				node.cemented[address] = len(node.chains[address])
+				forked = true
+				node.compete(address, competitor)
+			} else if forked && checks < 2 {
+				t.Error("block resubmitted before the fork was resolved")
+			}
+		case action == "account_info" && forked:
+			if checks++; checks == 2 {
+				// The competitor loses the election
+				chain := node.chains[address]
+				node.chains[address] = chain[:len(chain)-1]
+				delete(node.cemented, address)
+			}
+		}
+		return nil, false
+	}
+
+	if err := NewNanoDataStorage(rpc, &address, &privateKey).PutData(testPayload); err != nil {
+		t.Fatal(err)
+	}
+
+	if checks != 2 {
+		t.Errorf("checked the account %d times after the fork, want 2", checks)
+	}
+	if got := readBack(t, rpc, address); !bytes.Equal(got, testPayload) {
+		t.Fatalf("read %q", got)
+	}
+}
+
+func TestPutDataForkLost(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+	competitor := strings.Repeat("F0", 32)
+
+	processed := 0
+	node.hook = func(action string, request map[string]interface{}) (interface{}, bool) {
+		if action == "process" {
+			if processed++; processed == 2 {
+				// Another writer's block is already cemented on our previous
+				node.compete(address, competitor)
+			}
+		}
+		return nil, false
+	}
+
+	err := NewNanoDataStorage(rpc, &address, &privateKey).PutData(testPayload)
+	if err == nil || !strings.Contains(err.Error(), "not written by this upload") {
+		t.Fatalf("PutData = %v, want the foreign frontier to be refused", err)
+	}
+	if processed != 2 {
+		t.Errorf("processed %d blocks, nothing should be resubmitted after losing the fork", processed)
+	}
+}
+
+// Blocks above the cemented frontier that lost a fork are rebuilt from it.
+func TestPutDataRebuildsFromConfirmedFrontier(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+	competitor := strings.Repeat("F0", 32)
+
+	processed, rolledBack := 0, false
+	node.hook = func(action string, request map[string]interface{}) (interface{}, bool) {
+		if action != "process" {
+			return nil, false
+		}
+
+		if processed++; processed == 4 && !rolledBack {
+			// Our last two blocks are replaced by a competitor that's still unconfirmed
+			rolledBack = true
+			chain := node.chains[address]
+			node.chains[address] = chain[:len(chain)-2]
+			node.cemented[address] = len(chain) - 2
+			node.compete(address, competitor)
+		}
+		if processed == 5 {
+			// which then loses the election
+			chain := node.chains[address]
+			node.chains[address] = chain[:len(chain)-1]
+			delete(node.cemented, address)
+		}
+		return nil, false
+	}
+
+	if err := NewNanoDataStorage(rpc, &address, &privateKey).PutData(testPayload); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, block := range node.chain(address) {
+		if block.Hash == competitor {
+			t.Fatal("competitor left on the chain")
+		}
+	}
+	if got := readBack(t, rpc, address); !bytes.Equal(got, testPayload) {
+		t.Fatalf("read %q", got)
+	}
+}
+
+func TestPutDataRestoresRepresentative(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+	representative, _ := node.testAccount(t, 1)
+
+	storage := NewNanoDataStorage(rpc, &address, &privateKey)
+	if err := storage.SetRepresentative(representative); err != nil {
+		t.Fatal(err)
+	}
+
+	// The write fails part-way and leaves an unfinished frame behind
+	processed := 0
+	node.hook = func(action string, request map[string]interface{}) (interface{}, bool) {
+		if action == "process" {
+			if processed++; processed == 3 {
+				return map[string]string{"error": "Insufficient work"}, true
+			}
+		}
+		return nil, false
+	}
+
+	if err := storage.PutData(testPayload); err == nil || !strings.Contains(err.Error(), "Insufficient work") {
+		t.Fatalf("PutData = %v, want the write error", err)
+	}
+
+	chain := node.chain(address)
+	if last := chain[len(chain)-1]; last.Representative != representative {
+		t.Fatalf("representative left on %s", last.Representative)
+	}
+
+	// The restore block and the unfinished frame must not leak into the next message
+	node.hook = nil
+	if err := storage.PutData([]byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readBack(t, rpc, address); string(got) != "second" {
+		t.Fatalf("read %q", got)
+	}
+
+	chain = node.chain(address)
+	if last := chain[len(chain)-1]; last.Representative != representative {
+		t.Fatalf("representative left on %s after a complete write", last.Representative)
+	}
+}
+
+func TestPutDataRestoreFails(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+	representative, _ := node.testAccount(t, 1)
+
+	storage := NewNanoDataStorage(rpc, &address, &privateKey)
+	storage.SetRepresentative(representative)
+
+	processed := 0
+	node.hook = func(action string, request map[string]interface{}) (interface{}, bool) {
+		if action == "process" {
+			if processed++; processed >= 3 {
+				return map[string]string{"error": "Insufficient work"}, true
+			}
+		}
+		return nil, false
+	}
+
+	err := storage.PutData(testPayload)
+	if err == nil || !strings.Contains(err.Error(), "Insufficient work") || !strings.Contains(err.Error(), "failed to restore representative") {
+		t.Fatalf("PutData = %v, want the write and restore errors", err)
+	}
+}
+
+// A begin mark inside a payload is just data, even when it lands on a chunk boundary.
+func TestPutDataPayloadWithBeginMarks(t *testing.T) {
+	node, rpc := newTestNode(t)
+	address, privateKey := node.testAccount(t, 0)
+	representative, _ := node.testAccount(t, 1)
+
+	storage := NewNanoDataStorage(rpc, &address, &privateKey)
+	storage.SetRepresentative(representative)
+
+	beginProto, _ := hex.DecodeString(BEGIN_PROTOBUF)
+	beginHeader, _ := hex.DecodeString(BEGIN_HEADER)
+
+	// The begin mark takes the first 10 bytes of the frame, so payload offset 22 starts the second chunk
+	payload := make([]byte, 22, 200)
+	payload = append(payload, beginProto...)
+	payload = append(payload, make([]byte, 64-len(beginProto))...)
+	payload = append(payload, beginHeader...)
+	payload = append(payload, []byte("trailing data")...)
+
+	for _, data := range [][]byte{payload, []byte("next")} {
+		if err := storage.PutData(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := storage.GetData(&address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 || !bytes.Equal(data[0], payload) || string(data[1]) != "next" {
+		t.Fatalf("read %q", data)
+	}
+}
+
+// The estimate must match what a write publishes, whatever the storage adds around the payload.
+func TestEstimateBlocks(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		configure func(storage *NanoDataStorage, address string) error
+	}{
+		{"plain", func(*NanoDataStorage, string) error { return nil }},
+		{"representative", func(storage *NanoDataStorage, address string) error {
+			return storage.SetRepresentative(address)
+		}},
+		{"author key", func(storage *NanoDataStorage, _ string) error {
+			return storage.SetAuthorKey(strings.Repeat("AB", 32))
+		}},
+		{"link carrier", func(storage *NanoDataStorage, address string) error {
+			storage.SetCarrier(address, LinkCarrier{Amount: NewRaw(1)})
+			return storage.SetRepresentative(address)
+		}},
+	} {
+		for _, size := range []int{0, 12, 13, 200} {
+			node, rpc := newTestNode(t)
+			address, privateKey := node.testAccount(t, 0)
+			storage := NewNanoDataStorage(rpc, &address, &privateKey)
+
+			if err := test.configure(storage, address); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := storage.PutData(bytes.Repeat([]byte{1}, size)); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := storage.EstimateBlocks(size), len(node.chain(address))-1; got != want {
+				t.Errorf("%s, %d bytes: estimated %d blocks, wrote %d", test.name, size, got, want)
+			}
+		}
+	}
+
+	if EstimateBlocks(12) != 1 || EstimateBlocks(13) != 2 || EstimateBlocks(-1) != -1 {
+		t.Error("EstimateBlocks doesn't count the framing")
+	}
+}
@@ -0,0 +1,303 @@
+package nanoproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// StripedStorage spreads a payload over several accounts derived from one seed so the stripes can be written in parallel.
+// Account 0 holds the manifests describing each upload, accounts 1..stripes hold the stripes,
+// followed by the parity stripes when erasure coding is enabled.
+type StripedStorage struct {
+	rpc     *RPC
+	seed    *string
+	stripes int
+	parity  int
+}
+
+func NewStripedStorage(rpc *RPC, seed *string, stripes int) *StripedStorage {
+	return &StripedStorage{rpc, seed, stripes, 0}
+}
+
+// SetParity enables Reed-Solomon erasure coding with the given number of parity stripes,
+// letting readers rebuild a payload as long as any `stripes` of the stripes are still readable.
+func (s *StripedStorage) SetParity(parity int) {
+	s.parity = parity
+}
+
+// stripeRef points at one stripe of an upload: the account holding it, the first block of its message and its digest.
+type stripeRef struct {
+	account   []byte
+	firstHash []byte
+	digest    []byte
+}
+
+type stripeManifest struct {
+	size       uint64
+	shardSize  uint32
+	dataShards int
+	stripes    []stripeRef // Data stripes followed by parity stripes
+}
+
+var stripeManifestMagic = []byte("NPSM")
+
+const (
+	stripeManifestVersion = 1
+	stripeDigestSize      = 16
+)
+
+// Accounts returns the manifest account followed by the stripe accounts.
+func (s *StripedStorage) Accounts() ([]string, error) {
+	accounts := make([]string, 0, s.stripes+s.parity+1)
+
+	for i := 0; i <= s.stripes+s.parity; i++ {
+		_, address, err := DeriveAccount(*s.seed, uint32(i))
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, address)
+	}
+
+	return accounts, nil
+}
+
+// All derived accounts must be opened before writing, change blocks can't open an account.
+func (s *StripedStorage) PutData(data []byte) error {
+	if s.stripes < 1 || s.parity < 0 || s.stripes+s.parity > 255 {
+		return errors.New("stripe count must be between 1 and 255")
+	}
+
+	rs, err := NewReedSolomon(s.stripes, s.parity)
+	if err != nil {
+		return err
+	}
+
+	shards := rs.Encode(data)
+	manifest := stripeManifest{size: uint64(len(data)), shardSize: uint32(len(shards[0])), dataShards: s.stripes}
+	manifest.stripes = make([]stripeRef, len(shards))
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(shards))
+
+	for i, shard := range shards {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			ref, err := s.putShard(uint32(i+1), shard)
+			if err != nil {
+				errs[i] = fmt.Errorf("stripe %d: %v", i+1, err)
+				return
+			}
+
+			manifest.stripes[i] = ref
+		}()
+	}
+
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	storage, err := s.storage(0)
+	if err != nil {
+		return err
+	}
+
+	return storage.PutData(manifest.encode())
+}
+
+// GetData returns every payload described by the manifests stored on the manifest account.
+func (s *StripedStorage) GetData(address *string) ([][]byte, error) {
+	messages, err := NewNanoDataStorage(s.rpc, nil, nil).GetData(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var payloads [][]byte
+
+	for _, message := range messages {
+		manifest, ok := decodeStripeManifest(message)
+		if !ok {
+			continue // Not a manifest
+		}
+
+		payload, err := s.readStripes(manifest)
+		if err != nil {
+			return nil, err
+		}
+
+		payloads = append(payloads, payload)
+	}
+
+	return payloads, nil
+}
+
+func (s *StripedStorage) storage(index uint32) (*NanoDataStorage, error) {
+	privateKey, address, err := DeriveAccount(*s.seed, index)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewNanoDataStorage(s.rpc, &address, &privateKey), nil
+}
+
+func (s *StripedStorage) putShard(index uint32, shard []byte) (stripeRef, error) {
+	storage, err := s.storage(index)
+	if err != nil {
+		return stripeRef{}, err
+	}
+
+	hashes, err := storage.putMessage(RepresentativeCarrier{}, frameChunks(nil, shard))
+	if err != nil {
+		return stripeRef{}, err
+	}
+
+	account, _ := nanoAddressToPublicKey(*storage.address)
+	accountBytes, _ := hex.DecodeString(account)
+	firstHash, _ := hex.DecodeString(hashes[0])
+
+	return stripeRef{accountBytes, firstHash, shardDigest(shard)}, nil
+}
+
+// readStripes fetches every stripe concurrently, checks their digests and joins them back into the payload.
+// Missing or corrupted stripes are rebuilt from the parity stripes when there are enough of them.
+func (s *StripedStorage) readStripes(manifest stripeManifest) ([]byte, error) {
+	var wg sync.WaitGroup
+	shards := make([][]byte, len(manifest.stripes))
+	errs := make([]error, len(manifest.stripes))
+
+	for i, ref := range manifest.stripes {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			shard, err := s.readStripe(ref)
+			if err != nil {
+				errs[i] = fmt.Errorf("stripe %d: %v", i+1, err)
+				return
+			}
+
+			shards[i] = shard
+		}()
+	}
+
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		rs, rsErr := NewReedSolomon(manifest.dataShards, len(manifest.stripes)-manifest.dataShards)
+		if rsErr != nil || rs.Reconstruct(shards) != nil {
+			return nil, err
+		}
+	}
+
+	return joinShards(shards[:manifest.dataShards], manifest.size), nil
+}
+
+func (s *StripedStorage) readStripe(ref stripeRef) ([]byte, error) {
+	address, err := publicKeyToNanoAddress(ref.account)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := NewNanoDataStorage(s.rpc, nil, nil).GetMessage(MessageRef{Account: address, FirstHash: fmt.Sprintf("%X", ref.firstHash)})
+	if err != nil {
+		return nil, err
+	}
+
+	shard := message.Data
+
+	if !bytes.Equal(shardDigest(shard), ref.digest) {
+		return nil, fmt.Errorf("digest mismatch for message at %X", ref.firstHash)
+	}
+
+	return shard, nil
+}
+
+// splitShards cuts data into n equally sized shards, zero padding the last one.
+func splitShards(data []byte, n int) [][]byte {
+	shardSize := (len(data) + n - 1) / n
+	padded := make([]byte, shardSize*n)
+	copy(padded, data)
+
+	shards := make([][]byte, n)
+	for i := range shards {
+		shards[i] = padded[i*shardSize : (i+1)*shardSize]
+	}
+
+	return shards
+}
+
+func joinShards(shards [][]byte, size uint64) []byte {
+	data := bytes.Join(shards, nil)
+	if uint64(len(data)) > size {
+		data = data[:size]
+	}
+
+	return data
+}
+
+func shardDigest(shard []byte) []byte {
+	hasher, _ := blake2b.New(stripeDigestSize, nil)
+	hasher.Write(shard)
+	return hasher.Sum(nil)
+}
+
+// Manifest layout: magic, version, payload size (8), shard size (4), stripe count (1), parity stripe count (1),
+// then per stripe the account public key (32), the first block hash of its message (32) and its digest (16).
+func (m stripeManifest) encode() []byte {
+	var buffer bytes.Buffer
+
+	buffer.Write(stripeManifestMagic)
+	buffer.WriteByte(stripeManifestVersion)
+	binary.Write(&buffer, binary.BigEndian, m.size)
+	binary.Write(&buffer, binary.BigEndian, m.shardSize)
+	buffer.WriteByte(byte(m.dataShards))
+	buffer.WriteByte(byte(len(m.stripes) - m.dataShards))
+
+	for _, ref := range m.stripes {
+		buffer.Write(ref.account)
+		buffer.Write(ref.firstHash)
+		buffer.Write(ref.digest)
+	}
+
+	return buffer.Bytes()
+}
+
+func decodeStripeManifest(data []byte) (stripeManifest, bool) {
+	const refSize = 32 + 32 + stripeDigestSize
+
+	if len(data) < 19 || !bytes.Equal(data[:4], stripeManifestMagic) || data[4] != stripeManifestVersion {
+		return stripeManifest{}, false
+	}
+
+	m := stripeManifest{
+		size:       binary.BigEndian.Uint64(data[5:13]),
+		shardSize:  binary.BigEndian.Uint32(data[13:17]),
+		dataShards: int(data[17]),
+	}
+
+	count := m.dataShards + int(data[18])
+	refs := data[19:]
+
+	if len(refs) != count*refSize {
+		return stripeManifest{}, false
+	}
+
+	for i := 0; i < count; i++ {
+		ref := refs[i*refSize : (i+1)*refSize]
+		m.stripes = append(m.stripes, stripeRef{ref[:32], ref[32:64], ref[64:]})
+	}
+
+	return m, true
+}
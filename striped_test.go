@@ -0,0 +1,171 @@
+package nanoproto
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitJoinShards(t *testing.T) {
+	data := []byte("the quick brown fox jumps over the lazy dog")
+
+	for n := 1; n <= len(data)+1; n++ {
+		shards := splitShards(data, n)
+		if len(shards) != n {
+			t.Fatalf("splitShards(%d) returned %d shards", n, len(shards))
+		}
+		for _, shard := range shards {
+			if len(shard) != len(shards[0]) {
+				t.Fatalf("splitShards(%d) returned shards of different sizes", n)
+			}
+		}
+
+		if got := joinShards(shards, uint64(len(data))); !bytes.Equal(got, data) {
+			t.Fatalf("joinShards(splitShards(%d)) = %q", n, got)
+		}
+	}
+}
+
+func TestStripeManifestRoundTrip(t *testing.T) {
+	ref := func(b byte) stripeRef {
+		return stripeRef{bytes.Repeat([]byte{b}, 32), bytes.Repeat([]byte{b + 1}, 32), bytes.Repeat([]byte{b + 2}, stripeDigestSize)}
+	}
+
+	manifest := stripeManifest{size: 1000, shardSize: 334, dataShards: 3, stripes: []stripeRef{ref(1), ref(4), ref(7), ref(10), ref(13)}}
+
+	decoded, ok := decodeStripeManifest(manifest.encode())
+	if !ok {
+		t.Fatal("decodeStripeManifest rejected an encoded manifest")
+	}
+	if !reflect.DeepEqual(decoded, manifest) {
+		t.Fatalf("decodeStripeManifest = %+v, want %+v", decoded, manifest)
+	}
+
+	encoded := manifest.encode()
+	versioned := append([]byte{}, encoded...)
+	versioned[4] = 2
+
+	for _, bad := range [][]byte{encoded[:18], versioned, encoded[:len(encoded)-1], append([]byte("XXXX"), encoded[4:]...)} {
+		if _, ok := decodeStripeManifest(bad); ok {
+			t.Errorf("decodeStripeManifest accepted % x", bad[:8])
+		}
+	}
+}
+
+// stripedNode opens the manifest and stripe accounts of the test seed on a node.
+func stripedNode(t *testing.T, stripes, parity int) (*testNode, *StripedStorage, []string) {
+	t.Helper()
+
+	node, rpc := newTestNode(t)
+	for i := 0; i <= stripes+parity; i++ {
+		node.testAccount(t, uint32(i))
+	}
+
+	seed := strings.Repeat("5E", 32)
+	storage := NewStripedStorage(rpc, &seed, stripes)
+	storage.SetParity(parity)
+
+	accounts, err := storage.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return node, storage, accounts
+}
+
+func TestStripedPutGetData(t *testing.T) {
+	_, storage, accounts := stripedNode(t, 3, 0)
+
+	if err := storage.PutData(testPayload); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.PutData([]byte("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	payloads, err := storage.GetData(&accounts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payloads) != 2 || !bytes.Equal(payloads[0], testPayload) || string(payloads[1]) != "second" {
+		t.Fatalf("read %q", payloads)
+	}
+}
+
+func TestStripedRebuildsFromParity(t *testing.T) {
+	node, storage, accounts := stripedNode(t, 3, 2)
+
+	if err := storage.PutData(testPayload); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stripe accounts the node can't serve anymore
+	missing := map[string]bool{}
+	node.hook = func(action string, request map[string]interface{}) (interface{}, bool) {
+		if account, _ := request["account"].(string); action == "account_history" && missing[account] {
+			return map[string]string{"error": "Account not found"}, true
+		}
+		return nil, false
+	}
+
+	for _, lost := range [][]int{{1}, {2, 5}, {4, 5}} {
+		node.mu.Lock()
+		clear(missing)
+		for _, stripe := range lost {
+			missing[accounts[stripe]] = true
+		}
+		node.mu.Unlock()
+
+		payloads, err := storage.GetData(&accounts[0])
+		if err != nil {
+			t.Fatalf("stripes %v lost: %v", lost, err)
+		}
+		if len(payloads) != 1 || !bytes.Equal(payloads[0], testPayload) {
+			t.Fatalf("stripes %v lost: read %q", lost, payloads)
+		}
+	}
+
+	// More stripes lost than there are parity stripes
+	node.mu.Lock()
+	for _, stripe := range []int{1, 2, 3} {
+		missing[accounts[stripe]] = true
+	}
+	node.mu.Unlock()
+
+	if _, err := storage.GetData(&accounts[0]); err == nil {
+		t.Fatal("read a payload with three of five stripes lost")
+	}
+}
+
+// A stripe whose message doesn't match the digest in the manifest is rebuilt like a missing one.
+func TestStripedRebuildsCorruptStripe(t *testing.T) {
+	node, storage, accounts := stripedNode(t, 2, 1)
+
+	if err := storage.PutData(testPayload); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewNanoDataStorage(storage.rpc, nil, nil)
+	stripe, err := reader.GetData(&accounts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first data chunk of stripe 1 follows the open block and the begin mark
+	node.mu.Lock()
+	node.chains[accounts[1]][2].Representative = accounts[0]
+	node.mu.Unlock()
+
+	if corrupted, err := reader.GetData(&accounts[1]); err != nil || bytes.Equal(corrupted[0], stripe[0]) {
+		t.Fatalf("stripe wasn't corrupted: %v", err)
+	}
+
+	payloads, err := storage.GetData(&accounts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payloads) != 1 || !bytes.Equal(payloads[0], testPayload) {
+		t.Fatalf("read %q", payloads)
+	}
+}
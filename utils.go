@@ -21,22 +21,19 @@ var nanoAlphabetMap = func() map[rune]int {
 	return m
 }()
 
-// Credits: ChatGPT
-func convertBalanceToBytes(balanceStr string) ([]byte, error) {
-	var balanceBytes []byte = make([]byte, 16)
+// AddressToPublicKey returns the hex public key encoded in a nano_ or xrb_ address after validating its checksum.
+func AddressToPublicKey(address string) (string, error) {
+	return nanoAddressToPublicKey(address)
+}
 
-	// Convert string to big.Int
-	balanceBigInt := new(big.Int)
-	balanceBigInt, ok := balanceBigInt.SetString(balanceStr, 10) // Base 10 conversion
-	if !ok {
-		return balanceBytes, fmt.Errorf("failed to convert balance string to big.Int")
+// PublicKeyToAddress encodes a 32-byte hex public key as a nano_ address.
+func PublicKeyToAddress(publicKey string) (string, error) {
+	pubKey, err := hex.DecodeString(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode public key: %v", err)
 	}
 
-	// Convert big.Int to 16-byte big-endian representation
-	balanceBin := balanceBigInt.Bytes()                 // Get byte slice representation
-	copy(balanceBytes[16-len(balanceBin):], balanceBin) // Right-align in 16 bytes
-
-	return balanceBytes, nil
+	return publicKeyToNanoAddress(pubKey)
 }
 
 // Credits: ChatGPT
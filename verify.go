@@ -0,0 +1,199 @@
+package nanoproto
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Accounts allowed to sign epoch blocks on the live network (epoch v1 and v2).
+var epochSigners = []string{
+	"nano_3t6k35gi95xu6tergt6p69ck76ogmitsa8mnijtpxm9fkcm736xtoncuohr3",
+	"nano_3qb6o6i1tkzr6jwr5s7eehfxwg9x6eemitdinbpi7u8bjjwsgqfj4wzser3x",
+}
+
+// Lowest work thresholds a block of each kind may have had across epochs.
+const (
+	workThresholdSend    = 0xffffffc000000000 // Send, change and epoch blocks (epoch 1 threshold)
+	workThresholdReceive = 0xfffffe0000000000 // Open and receive blocks (epoch 2 receive threshold)
+)
+
+// VerificationError reports the first block of a history that failed verification.
+type VerificationError struct {
+	Account string
+	Hash    string
+	Reason  string
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("history of %s failed verification at block %s: %s", e.Account, e.Hash, e.Reason)
+}
+
+// SetVerifyHistory turns on verified reads: raw blocks are checked from the open block to the frontier
+// (hashes, previous linkage, subtypes, signatures and work) and the read fails on any mismatch.
+// Full-history and incremental (GetDataSince, ReadSince, KV) reads are verified, GetMessage can't check
+// linkage without the blocks before the message.
+func (s *NanoDataStorage) SetVerifyHistory(verify bool) {
+	s.verify = verify
+}
+
+// verifiedHistory returns the history of address up to the frontier reported by the node after verifying it.
+func verifiedHistory(address string, history []AccountHistoryRepChange, frontier string) ([]AccountHistoryRepChange, error) {
+	history, ok := truncateHistory(history, frontier)
+	if !ok {
+		return nil, &VerificationError{address, frontier, "frontier is missing from the history"}
+	}
+
+	return history, VerifyHistory(address, history)
+}
+
+// VerifyHistory checks a raw account history that starts at the open block, oldest first: every block hash is
+// recomputed, each block must point at the one before it, the reported subtype must match the one derived
+// from the balance and link, signatures are checked against the account key (or the epoch signers for epoch
+// blocks) and work must reach the minimum threshold for its kind.
+func VerifyHistory(account string, history []AccountHistoryRepChange) error {
+	pubKey, err := nanoAddressToPublicKey(account)
+	if err != nil {
+		return err
+	}
+
+	accountKey, _ := hex.DecodeString(pubKey)
+	epochKeys := make([][]byte, 0, len(epochSigners))
+	for _, signer := range epochSigners {
+		key, _ := nanoAddressToPublicKey(signer)
+		keyBytes, _ := hex.DecodeString(key)
+		epochKeys = append(epochKeys, keyBytes)
+	}
+
+	fail := func(item AccountHistoryRepChange, reason string, args ...any) error {
+		return &VerificationError{account, item.Hash, fmt.Sprintf(reason, args...)}
+	}
+
+	var entries []BatchEntry
+	var epochs []int // Indexes of epoch blocks, verified separately against several keys
+	previous := ZeroHash
+	representative := ""
+	var balance Raw
+
+	for i, item := range history {
+		if item.Type != "state" {
+			return fail(item, "unsupported legacy %s block", item.Type)
+		}
+
+		if !strings.EqualFold(item.Previous, previous) {
+			return fail(item, "previous is %s, expected %s", item.Previous, previous)
+		}
+
+		hash, err := stateBlockHash(account, item.Previous, item.Representative, item.Balance, item.Link)
+		if err != nil {
+			return fail(item, "%v", err)
+		}
+		if !strings.EqualFold(hex.EncodeToString(hash), item.Hash) {
+			return fail(item, "block hashes to %X", hash)
+		}
+
+		// The subtype isn't part of the hash, so a node could relabel blocks to hide or inject chunks
+		if subtype := blockSubtype(item, balance); item.Subtype != subtype {
+			return fail(item, "reported as %s but is a %s block", item.Subtype, subtype)
+		}
+
+		if err := checkWork(item, accountKey); err != nil {
+			return fail(item, "%v", err)
+		}
+
+		signature, err := hex.DecodeString(item.Signature)
+		if err != nil {
+			return fail(item, "invalid signature hex")
+		}
+
+		if item.Subtype == "epoch" {
+			// Epoch blocks only upgrade the account, they can't move funds or change the representative
+			if item.Representative != representative || item.Balance.Cmp(balance) != 0 {
+				return fail(item, "epoch block changes the account")
+			}
+
+			valid := false
+			for _, key := range epochKeys {
+				valid = valid || NewEd25519().Verify(hash, key, signature)
+			}
+			if !valid {
+				return fail(item, "invalid epoch signature")
+			}
+
+			epochs = append(epochs, i)
+		} else {
+			entries = append(entries, BatchEntry{accountKey, hash, signature})
+		}
+
+		previous = item.Hash
+		representative = item.Representative
+		balance = item.Balance
+	}
+
+	if invalid := NewEd25519().VerifyBatch(entries); len(invalid) > 0 {
+		// Map the batch index back to the history, skipping epoch blocks
+		index := invalid[0]
+		for _, epoch := range epochs {
+			if epoch <= index {
+				index++
+			}
+		}
+
+		return fail(history[index], "invalid signature")
+	}
+
+	return nil
+}
+
+// blockSubtype derives the subtype of a state block from the hashed fields and the balance before it.
+func blockSubtype(item AccountHistoryRepChange, previousBalance Raw) string {
+	switch {
+	case strings.EqualFold(item.Previous, ZeroHash):
+		return "open"
+	case item.Balance.Cmp(previousBalance) < 0:
+		return "send"
+	case item.Balance.Cmp(previousBalance) > 0:
+		return "receive"
+	case strings.EqualFold(item.Link, ZeroHash):
+		return "change"
+	}
+
+	return "epoch" // Neither moves funds nor has a zero link
+}
+
+// checkWork validates the proof of work of a block: blake2b-64(work || root) must reach the threshold,
+// the root being the previous block or the account key for open blocks.
+func checkWork(item AccountHistoryRepChange, accountKey []byte) error {
+	work, err := strconv.ParseUint(item.Work, 16, 64)
+	if err != nil {
+		return fmt.Errorf("invalid work %q", item.Work)
+	}
+
+	root, _ := hex.DecodeString(item.Previous)
+	if item.Previous == ZeroHash {
+		root = accountKey
+	}
+
+	workBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(workBytes, work)
+
+	hasher, _ := blake2b.New(8, nil)
+	hasher.Write(workBytes)
+	hasher.Write(root)
+	difficulty := binary.LittleEndian.Uint64(hasher.Sum(nil))
+
+	threshold := uint64(workThresholdSend)
+	if item.Subtype == "receive" || item.Subtype == "open" {
+		threshold = workThresholdReceive
+	}
+
+	if difficulty < threshold {
+		return fmt.Errorf("work difficulty %016x is below %016x", difficulty, threshold)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package nanoproto
+
+import (
+	"strings"
+	"testing"
+)
+
+// Proof of work for the blocks of verifiedFixture, found offline. The blocks hash the same every time,
+// so the work stays valid as long as the fixture doesn't change.
+var verifiedFixtureWork = []string{"00000000003f2481", "00000000040dd2e7", "0000000001e1c6cc", "00000000007f81c2", "0000000001bd9ecc"}
+
+// verifiedFixture returns a valid history of five state blocks: open, change, send, receive and change.
+func verifiedFixture(t *testing.T) (string, string, []AccountHistoryRepChange) {
+	t.Helper()
+
+	privateKey, account, err := DeriveAccount(strings.Repeat("5E", 32), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, representative, _ := DeriveAccount(strings.Repeat("5E", 32), 1)
+
+	amount := func(nano string) Raw {
+		r, _ := ParseNano(nano)
+		return r
+	}
+
+	steps := []struct {
+		subtype        string
+		representative string
+		balance        Raw
+		link           string
+	}{
+		{"open", account, amount("1"), strings.Repeat("AA", 32)},
+		{"change", representative, amount("1"), ZeroHash},
+		{"send", representative, amount("0.5"), strings.Repeat("BB", 32)},
+		{"receive", representative, amount("2"), strings.Repeat("CC", 32)},
+		{"change", account, amount("2"), ZeroHash},
+	}
+
+	var history []AccountHistoryRepChange
+	previous := ZeroHash
+
+	for i, step := range steps {
+		block := NewSendBlock(account, previous, step.representative, step.balance, step.link)
+		if err := block.Sign(privateKey); err != nil {
+			t.Fatal(err)
+		}
+
+		hash, _ := block.Hash()
+		history = append(history, AccountHistoryRepChange{
+			Type:           "state",
+			Subtype:        step.subtype,
+			Representative: block.Representative,
+			Hash:           hash,
+			Previous:       previous,
+			Balance:        block.Balance,
+			Link:           block.Link,
+			Signature:      block.Signature,
+			Work:           verifiedFixtureWork[i],
+		})
+		previous = hash
+	}
+
+	return account, privateKey, history
+}
+
+func TestVerifyHistory(t *testing.T) {
+	account, _, history := verifiedFixture(t)
+
+	if err := VerifyHistory(account, history); err != nil {
+		t.Fatalf("valid history: %v", err)
+	}
+	if err := VerifyHistory(account, nil); err != nil {
+		t.Fatalf("empty history: %v", err)
+	}
+
+	_, _, fixture := verifiedFixture(t)
+	_, otherAccount, _ := DeriveAccount(strings.Repeat("5E", 32), 2)
+
+	cases := []struct {
+		name   string
+		block  int
+		reason string
+		tamper func(h []AccountHistoryRepChange)
+	}{
+		{"tampered hash", 2, "hashes to", func(h []AccountHistoryRepChange) { h[2].Balance = NewRaw(1) }},
+		{"claimed hash", 1, "hashes to", func(h []AccountHistoryRepChange) { h[1].Hash = strings.Repeat("0F", 32) }},
+		{"broken previous", 3, "previous is", func(h []AccountHistoryRepChange) { h[3].Previous = h[1].Hash }},
+		{"missing block", 2, "previous is", func(h []AccountHistoryRepChange) { copy(h[2:], h[3:]) }},
+		{"bad signature", 3, "invalid signature", func(h []AccountHistoryRepChange) { h[3].Signature = h[2].Signature }},
+		{"change relabeled as receive", 1, "reported as receive", func(h []AccountHistoryRepChange) { h[1].Subtype = "receive" }},
+		{"send relabeled as change", 2, "reported as change", func(h []AccountHistoryRepChange) { h[2].Subtype = "change" }},
+		{"receive relabeled as send", 3, "reported as send", func(h []AccountHistoryRepChange) { h[3].Subtype = "send" }},
+		{"insufficient work", 4, "work difficulty", func(h []AccountHistoryRepChange) { h[4].Work = "0000000000000000" }},
+		{"invalid work", 0, "invalid work", func(h []AccountHistoryRepChange) { h[0].Work = "xyz" }},
+		{"legacy block", 0, "legacy", func(h []AccountHistoryRepChange) { h[0].Type = "open" }},
+	}
+
+	for _, c := range cases {
+		tampered := append([]AccountHistoryRepChange(nil), fixture...)
+		c.tamper(tampered)
+
+		err := VerifyHistory(account, tampered)
+
+		verr, ok := err.(*VerificationError)
+		if !ok {
+			t.Errorf("%s: VerifyHistory = %v, want a *VerificationError", c.name, err)
+			continue
+		}
+		if verr.Hash != tampered[c.block].Hash || !strings.Contains(verr.Reason, c.reason) {
+			t.Errorf("%s: failed at %s with %q, want block %d and %q", c.name, verr.Hash, verr.Reason, c.block, c.reason)
+		}
+	}
+
+	if err := VerifyHistory(otherAccount, history); err == nil {
+		t.Error("history verified for another account")
+	}
+}